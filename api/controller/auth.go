@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/logic/appservice"
+)
+
+// RefreshToken 使用 Refresh Token 换取新的 Access Token 和 Refresh Token
+func RefreshToken(c *gin.Context) {
+	tokenRequest := new(request.TokenRefresh)
+	if err := c.ShouldBind(tokenRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	tokenReply, err := appservice.NewAuthAppSvc(c).RefreshToken(tokenRequest)
+	if err != nil {
+		if errors.Is(err, errcode.ErrToken) {
+			app.NewResponse(c).Error(errcode.ErrToken)
+			return
+		}
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).Success(tokenReply)
+}
+
+// RevokeToken 吊销 Refresh Token 所在的会话, 用于退出登录
+func RevokeToken(c *gin.Context) {
+	tokenRequest := new(request.TokenRefresh)
+	if err := c.ShouldBind(tokenRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewAuthAppSvc(c).RevokeToken(tokenRequest)
+	if err != nil {
+		if errors.Is(err, errcode.ErrToken) {
+			app.NewResponse(c).Error(errcode.ErrToken)
+			return
+		}
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
@@ -5,12 +5,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github/lhh-gh/go-mall/api/request"
 	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/auth"
 	"github/lhh-gh/go-mall/comon/errcode"
 	"github/lhh-gh/go-mall/comon/logger"
 	"github/lhh-gh/go-mall/config"
 	"github/lhh-gh/go-mall/library"
 	"github/lhh-gh/go-mall/logic/appservice"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func TestConfigRead(c *gin.Context) {
@@ -79,6 +82,16 @@ func TestResponseObj(c *gin.Context) {
 func TestResponseList(c *gin.Context) {
 
 	pagination := app.NewPagination(c)
+	// 按白名单解析排序和过滤参数, 例如: ?sort=-age,name&age[gte]=18
+	// 正式开发时把 listQuery.Scopes() 传给DAO: DB().Scopes(listQuery.Scopes()...)
+	_, appErr := app.NewListQuery(c, app.QueryWhitelist{
+		"name": {Column: "name", Sortable: true, Operators: []string{app.FilterOpEq, app.FilterOpLike}},
+		"age":  {Column: "age", Sortable: true, Operators: []string{app.FilterOpEq, app.FilterOpGte, app.FilterOpLte}},
+	})
+	if appErr != nil {
+		app.NewResponse(c).Error(appErr)
+		return
+	}
 	// Mock fetch list data from db
 	data := []struct {
 		Name string `json:"name"`
@@ -129,8 +142,8 @@ func TestCreateDemoOrder(c *gin.Context) {
 		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
 		return
 	}
-	// 验证用户信息 Token 然后把UserID赋值上去 这里测试就直接赋值了
-	request.UserId = 123453453
+	// 用户ID取认证中间件验证Token后放到上下文中的用户身份
+	request.UserId = auth.GetUserId(c)
 	svc := appservice.NewDemoAppSvc(c)
 	reply, err := svc.CreateDemoOrder(request)
 	if err != nil {
@@ -140,8 +153,40 @@ func TestCreateDemoOrder(c *gin.Context) {
 
 	app.NewResponse(c).Success(reply)
 }
+
+// TestIssueToken 测试签发Token, 正式开发时在用户登录成功后签发
+func TestIssueToken(c *gin.Context) {
+	tokenReply, err := appservice.NewAuthAppSvc(c).IssueToken(123453453)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).Success(tokenReply)
+}
+
+// TestSSE 测试SSE推送, 模拟推送任务进度, 断线重连后从 Last-Event-ID 继续推送
+func TestSSE(c *gin.Context) {
+	stream := app.NewSSEStream(c)
+	progress, _ := strconv.Atoi(stream.LastEventId())
+	events := make(chan *app.SSEEvent)
+	go func() {
+		defer close(events)
+		for progress < 100 {
+			progress += 10
+			time.Sleep(time.Second)
+			select {
+			case <-stream.Done():
+				return
+			case events <- &app.SSEEvent{Id: strconv.Itoa(progress), Event: "progress", Data: gin.H{"progress": progress}}:
+			}
+		}
+	}()
+	stream.Stream(events)
+}
+
 func TestForHttpToolGet(c *gin.Context) {
-	ipDetail, err := library.NewWhoisLib(c).GetHostIpDetail()
+	ipDetail, err := library.NewWhoisLib(c).GetIpDetail(c.Query("ip"))
 	if err != nil {
 		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
 		return
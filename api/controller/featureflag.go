@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/featureflag"
+)
+
+// ListFeatureFlags 查看所有功能开关的当前状态
+func ListFeatureFlags(c *gin.Context) {
+	app.NewResponse(c).Success(featureflag.List(c))
+}
+
+// SetFeatureFlag 在Redis中覆盖功能开关, 最多几秒后在所有服务实例上生效
+func SetFeatureFlag(c *gin.Context) {
+	flagRequest := new(request.FeatureFlagSet)
+	if err := c.ShouldBind(flagRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	flag := &featureflag.Flag{
+		Enabled:    *flagRequest.Enabled,
+		Percentage: flagRequest.Percentage,
+		UserIds:    flagRequest.UserIds,
+	}
+	if err := featureflag.SetOverride(c, flagRequest.Name, flag); err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+// DeleteFeatureFlag 删除功能开关在Redis中的覆盖, 恢复使用配置文件中的配置
+func DeleteFeatureFlag(c *gin.Context) {
+	flagRequest := new(request.FeatureFlagDelete)
+	if err := c.ShouldBind(flagRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	if err := featureflag.DelOverride(c, flagRequest.Name); err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/middleware"
+	"github/lhh-gh/go-mall/logic/appservice"
+)
+
+// CreateOpenApp 为合作方创建应用, 响应中的 app_secret 只返回这一次
+func CreateOpenApp(c *gin.Context) {
+	createRequest := new(request.OpenAppCreate)
+	if err := c.ShouldBind(createRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	openApp, err := appservice.NewOpenAppAppSvc(c).CreateOpenApp(createRequest)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).Success(openApp)
+}
+
+// UpdateOpenAppStatus 启用或停用合作方应用
+func UpdateOpenAppStatus(c *gin.Context) {
+	statusRequest := new(request.OpenAppStatus)
+	if err := c.ShouldBind(statusRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewOpenAppAppSvc(c).UpdateOpenAppStatus(statusRequest)
+	if errors.Is(err, errcode.ErrOpenAppNotExists) {
+		app.NewResponse(c).Error(errcode.ErrOpenAppNotExists)
+		return
+	}
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).SuccessOk()
+}
+
+// OpenCreateDemoOrder 合作方通过开放接口创建订单, 请求已经通过签名校验
+// 订单归属于签名对应的合作方应用, 合作方不能替平台用户下单, 请求中指定用户ID时拒绝
+func OpenCreateDemoOrder(c *gin.Context) {
+	orderRequest := new(request.DemoOrderCreate)
+	if err := c.ShouldBind(orderRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	if orderRequest.UserId != 0 {
+		app.NewResponse(c).Error(errcode.ErrForbidden)
+		return
+	}
+	orderRequest.OpenAppId = middleware.GetOpenAppId(c)
+	orderReply, err := appservice.NewDemoAppSvc(c).CreateDemoOrder(orderRequest)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).Success(orderReply)
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/reply"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/middleware"
+	"github/lhh-gh/go-mall/logic/appservice"
+	"strconv"
+)
+
+// ListUserRoles 查询用户绑定的角色
+func ListUserRoles(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if err != nil || userId <= 0 {
+		app.NewResponse(c).Error(errcode.ErrParams)
+		return
+	}
+	roles, err := appservice.NewRbacAppSvc(c).GetUserRoles(userId)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		return
+	}
+
+	app.NewResponse(c).Success(roles)
+}
+
+// CreateRoleBinding 为用户绑定角色
+func CreateRoleBinding(c *gin.Context) {
+	bindingRequest := new(request.RoleBinding)
+	if err := c.ShouldBind(bindingRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewRbacAppSvc(c).BindUserRole(bindingRequest)
+	rbacResponse(c, err)
+}
+
+// DeleteRoleBinding 解除用户与角色的绑定
+func DeleteRoleBinding(c *gin.Context) {
+	bindingRequest := new(request.RoleBinding)
+	if err := c.ShouldBind(bindingRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewRbacAppSvc(c).UnbindUserRole(bindingRequest)
+	rbacResponse(c, err)
+}
+
+// GrantRolePermission 为角色授予权限
+func GrantRolePermission(c *gin.Context) {
+	permissionRequest := new(request.RolePermission)
+	if err := c.ShouldBind(permissionRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewRbacAppSvc(c).GrantRolePermission(permissionRequest)
+	rbacResponse(c, err)
+}
+
+// RevokeRolePermission 收回角色的权限
+func RevokeRolePermission(c *gin.Context) {
+	permissionRequest := new(request.RolePermission)
+	if err := c.ShouldBind(permissionRequest); err != nil {
+		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
+		return
+	}
+	err := appservice.NewRbacAppSvc(c).RevokeRolePermission(permissionRequest)
+	rbacResponse(c, err)
+}
+
+// ListRouteAudits 查看路由审计结果, 用于检查哪些接口没有声明权限以及哪些接口无法审计
+func ListRouteAudits(c *gin.Context) {
+	audits := middleware.GetRouteAudits()
+	replyAudits := make([]*reply.RouteAudit, 0, len(audits))
+	for _, audit := range audits {
+		replyAudits = append(replyAudits, &reply.RouteAudit{
+			Method:             audit.Method,
+			Path:               audit.Path,
+			PermissionDeclared: audit.PermissionDeclared,
+			Handlers:           audit.Handlers,
+			Unaudited:          audit.Unaudited,
+		})
+	}
+
+	app.NewResponse(c).Success(replyAudits)
+}
+
+func rbacResponse(c *gin.Context, err error) {
+	if err == nil {
+		app.NewResponse(c).SuccessOk()
+		return
+	}
+	if errors.Is(err, errcode.ErrRoleNotExists) {
+		app.NewResponse(c).Error(errcode.ErrRoleNotExists)
+		return
+	}
+	if errors.Is(err, errcode.ErrPermissionNotExists) {
+		app.NewResponse(c).Error(errcode.ErrPermissionNotExists)
+		return
+	}
+	app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+}
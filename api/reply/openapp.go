@@ -0,0 +1,9 @@
+package reply
+
+type OpenApp struct {
+	AppKey    string `json:"app_key"`
+	AppSecret string `json:"app_secret"`
+	Name      string `json:"name"`
+	Status    int    `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
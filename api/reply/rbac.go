@@ -0,0 +1,16 @@
+package reply
+
+type Role struct {
+	Id          int64  `json:"id"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RouteAudit struct {
+	Method             string   `json:"method"`
+	Path               string   `json:"path"`
+	PermissionDeclared bool     `json:"permission_declared"`
+	Handlers           []string `json:"handlers"`
+	Unaudited          string   `json:"unaudited,omitempty"`
+}
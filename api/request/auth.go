@@ -0,0 +1,5 @@
+package request
+
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
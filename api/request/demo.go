@@ -10,4 +10,6 @@ type DemoOrderCreate struct {
 	BillMoney int64 `json:"bill_money" binding:"required"`
 	// 这个字段演示的时候因为没创建订单快照表所以不写库
 	OrderGoodsId int64 `json:"order_goods_id" binding:"required"`
+	// 通过开放接口下单的合作方应用, 由签名校验的结果设置, 不从请求中读取
+	OpenAppId int64 `json:"-"`
 }
@@ -0,0 +1,12 @@
+package request
+
+type FeatureFlagSet struct {
+	Name       string  `json:"name" binding:"required"`
+	Enabled    *bool   `json:"enabled" binding:"required"`
+	Percentage *int    `json:"percentage" binding:"omitempty,min=0,max=100"`
+	UserIds    []int64 `json:"user_ids"`
+}
+
+type FeatureFlagDelete struct {
+	Name string `json:"name" binding:"required"`
+}
@@ -0,0 +1,10 @@
+package request
+
+type OpenAppCreate struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type OpenAppStatus struct {
+	AppKey  string `json:"app_key" binding:"required"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+}
@@ -0,0 +1,11 @@
+package request
+
+type RoleBinding struct {
+	UserId   int64  `json:"user_id" binding:"required"`
+	RoleCode string `json:"role_code" binding:"required"`
+}
+
+type RolePermission struct {
+	RoleCode       string `json:"role_code" binding:"required"`
+	PermissionCode string `json:"permission_code" binding:"required"`
+}
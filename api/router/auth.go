@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/controller"
+	"github/lhh-gh/go-mall/comon/middleware"
+)
+
+func registerAuthRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /auth 开头
+	g := rg.Group("/auth/")
+	g.Use(middleware.Cors("auth"))
+	g.OPTIONS("*path", middleware.CorsPreflight)
+	// 刷新Token
+	g.POST("token/refresh", controller.RefreshToken)
+	// 吊销Token
+	g.POST("token/revoke", controller.RevokeToken)
+}
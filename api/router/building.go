@@ -3,11 +3,16 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github/lhh-gh/go-mall/api/controller"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/middleware"
+	"github/lhh-gh/go-mall/config"
 )
 
 func registerBuildingRoutes(rg *gin.RouterGroup) {
 	// 这个路由组中的路由都以 /building 开头
 	g := rg.Group("/building/")
+	g.Use(middleware.Cors("building"), middleware.Maintenance("building"), middleware.RateLimit("building"))
+	g.OPTIONS("*path", middleware.CorsPreflight)
 	// 测试 Ping
 	g.GET("ping", controller.TestPing)
 	// 测试日志文件的读取
@@ -29,9 +34,15 @@ func registerBuildingRoutes(rg *gin.RouterGroup) {
 	// 测试GORM Loggeer
 	g.GET("gorm-logger-test", controller.TestGormLogger)
 	// 演示代码逻辑分层, 测试 Create Demo Order
-	g.POST("create-demo-order", controller.TestCreateDemoOrder)
+	g.POST("create-demo-order", middleware.AuthUser(), middleware.RateLimit("building_user"), middleware.Idempotency(), controller.TestCreateDemoOrder)
+	// 测试签发Token, 接口不需要认证, 只在开发环境注册
+	if config.App.Env == enum.ModeDev {
+		g.GET("token-issue-test", controller.TestIssueToken)
+	}
+	// 测试SSE推送
+	g.GET("sse-test", controller.TestSSE)
 	// 测试封装的httptool
 	g.GET("httptool-get-test", controller.TestForHttpToolGet)
-	g.GET("httptool-post-test", controller.TestForHttpToolPost)
+	g.GET("httptool-post-test", middleware.AuthUser(), middleware.RateLimit("building_user"), controller.TestForHttpToolPost)
 
 }
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/controller"
+	"github/lhh-gh/go-mall/comon/middleware"
+)
+
+func registerFeatureFlagRoutes(rg *gin.RouterGroup) {
+	// 功能开关和维护模式的管理接口, 只有拥有 feature_flag:manage 权限的用户可以访问
+	g := rg.Group("/admin/feature-flag/")
+	g.Use(middleware.IpAccess("admin"), middleware.Cors("admin"), middleware.AuthUser(), middleware.RequirePermission("feature_flag:manage"))
+	g.OPTIONS("*path", middleware.CorsPreflight)
+	g.GET("list", controller.ListFeatureFlags)
+	g.POST("set", controller.SetFeatureFlag)
+	g.POST("delete", controller.DeleteFeatureFlag)
+}
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/controller"
+	"github/lhh-gh/go-mall/comon/middleware"
+)
+
+func registerOpenAppRoutes(rg *gin.RouterGroup) {
+	// 合作方应用的管理接口, 只有拥有 open_app:manage 权限的用户可以访问
+	admin := rg.Group("/admin/open-app/")
+	admin.Use(middleware.IpAccess("admin"), middleware.Cors("admin"), middleware.AuthUser(), middleware.RequirePermission("open_app:manage"))
+	admin.OPTIONS("*path", middleware.CorsPreflight)
+	admin.POST("create", controller.CreateOpenApp)
+	admin.POST("update-status", controller.UpdateOpenAppStatus)
+
+	// 开放接口, 合作方服务端调用, 请求需要携带签名
+	g := rg.Group("/open/")
+	g.Use(middleware.Maintenance("open"), middleware.IpAccess("open"), middleware.VerifySignature(), middleware.RateLimit("open"))
+	g.POST("order/create-demo-order", controller.OpenCreateDemoOrder)
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/api/controller"
+	"github/lhh-gh/go-mall/comon/middleware"
+)
+
+func registerRbacRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /admin/rbac 开头, 只有拥有 rbac:manage 权限的用户可以访问
+	g := rg.Group("/admin/rbac/")
+	g.Use(middleware.IpAccess("admin"), middleware.Cors("admin"), middleware.AuthUser(), middleware.RequirePermission("rbac:manage"))
+	g.OPTIONS("*path", middleware.CorsPreflight)
+	// 用户角色绑定
+	g.GET("role-binding/list", controller.ListUserRoles)
+	g.POST("role-binding/create", controller.CreateRoleBinding)
+	g.POST("role-binding/delete", controller.DeleteRoleBinding)
+	// 角色权限
+	g.POST("role-permission/grant", controller.GrantRolePermission)
+	g.POST("role-permission/revoke", controller.RevokeRolePermission)
+	// 路由权限审计
+	g.GET("route-audit", controller.ListRouteAudits)
+}
@@ -2,12 +2,24 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/metrics"
 	"github/lhh-gh/go-mall/comon/middleware"
 )
 
 func RegisterRoutes(engine *gin.Engine) {
-	// use global middlewares
-	engine.Use(middleware.StartTrace(), middleware.LogAccess(), middleware.GinPanicRecovery())
+	// Prometheus 采集接口在全局中间件之前注册, 不记访问日志也不计入接口指标
+	// 指标中有路由、下游Host和SQL等内部信息, 和管理接口一样只允许内网访问
+	engine.GET("/metrics", middleware.IpAccess("admin"), gin.WrapH(metrics.Handler()))
+
+	// use global middlewares, RouteAuditProbe 必须放在第一位
+	engine.Use(middleware.RouteAuditProbe(), middleware.Metrics(), middleware.StartTrace(), middleware.LogAccess(), middleware.GinPanicRecovery(), middleware.SecurityHeaders(), middleware.Timeout())
 	routeGroup := engine.Group("")
 	registerBuildingRoutes(routeGroup)
+	registerAuthRoutes(routeGroup)
+	registerRbacRoutes(routeGroup)
+	registerOpenAppRoutes(routeGroup)
+	registerFeatureFlagRoutes(routeGroup)
+
+	// 所有路由注册完成后审计路由的权限声明
+	middleware.AuditRoutes(engine)
 }
@@ -0,0 +1,173 @@
+package alert
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警分发: 把程序中出现的异常(目前是接口panic)发送到已注册的告警渠道
+// 同一个指纹的告警在去重窗口内只发送一次, 窗口内被忽略的次数会附带在下一次告警中
+
+// DefaultDedupWindow 默认的去重窗口
+const DefaultDedupWindow = 10 * time.Minute
+
+// sendTimeout 单个渠道发送告警的超时时间
+const sendTimeout = 10 * time.Second
+
+// Event 告警事件
+type Event struct {
+	Title       string    `json:"title"`
+	Fingerprint string    `json:"fingerprint"` // 去重用的指纹, panic 告警是调用栈的指纹
+	Message     string    `json:"message"`
+	Stack       string    `json:"stack,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	TraceId     string    `json:"trace_id,omitempty"`
+	App         string    `json:"app"`
+	Env         string    `json:"env"`
+	Suppressed  int       `json:"suppressed"` // 上一次发送后在去重窗口内被忽略的次数
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// Sink 告警渠道
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event *Event) error
+}
+
+type dedupEntry struct {
+	lastSent   time.Time
+	suppressed int
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   []Sink
+
+	dedupMu     sync.Mutex
+	dedupWindow = DefaultDedupWindow
+	dedupIndex  = make(map[string]*dedupEntry) // 指纹 => 最近一次发送的信息
+)
+
+func init() {
+	if config.Alert == nil {
+		return
+	}
+	if config.Alert.DedupWindow > 0 {
+		dedupWindow = config.Alert.DedupWindow
+	}
+	if config.Alert.Webhook.Url != "" {
+		Register(NewWebhookSink(config.Alert.Webhook.Url))
+	}
+	if config.Alert.Email.Host != "" && len(config.Alert.Email.To) > 0 {
+		Register(NewEmailSink(config.Alert.Email))
+	}
+	if config.Alert.File.Path != "" {
+		Register(NewFileSink(config.Alert.File.Path))
+	}
+}
+
+// Register 注册告警渠道
+func Register(sink Sink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks = append(sinks, sink)
+}
+
+// Dispatch 异步地把告警发送到所有渠道, 去重窗口内已经发送过的告警会被忽略
+func Dispatch(ctx context.Context, event *Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if event.App == "" {
+		event.App = config.App.Name
+	}
+	if event.Env == "" {
+		event.Env = config.App.Env
+	}
+	if !acquire(event) {
+		return
+	}
+
+	sinksMu.RLock()
+	targets := make([]Sink, len(sinks))
+	copy(targets, sinks)
+	sinksMu.RUnlock()
+
+	// 告警在请求结束后仍要发送完, 不能跟着请求一起被取消
+	ctx = context.WithoutCancel(ctx)
+	for _, sink := range targets {
+		go func(sink Sink) {
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+			if err := sink.Send(sendCtx, event); err != nil {
+				logger.New(ctx).Error("alert_send_error", "sink", sink.Name(), "fingerprint", event.Fingerprint, "err", err)
+			}
+		}(sink)
+	}
+}
+
+// acquire 判断告警在去重窗口内是否已经发送过, 需要发送时返回 true
+func acquire(event *Event) bool {
+	dedupMu.Lock()
+	defer dedupMu.Unlock()
+	now := time.Now()
+	for fingerprint, entry := range dedupIndex {
+		if now.Sub(entry.lastSent) >= dedupWindow && entry.suppressed == 0 {
+			delete(dedupIndex, fingerprint)
+		}
+	}
+	entry, ok := dedupIndex[event.Fingerprint]
+	if ok && now.Sub(entry.lastSent) < dedupWindow {
+		entry.suppressed++
+		return false
+	}
+	if ok {
+		event.Suppressed = entry.suppressed
+	}
+	dedupIndex[event.Fingerprint] = &dedupEntry{lastSent: now}
+	return true
+}
+
+var (
+	goroutineLinePattern = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	stackOffsetPattern   = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+	stackArgsPattern     = regexp.MustCompile(`\([^()]*\)$`)
+	createdByPattern     = regexp.MustCompile(` in goroutine \d+$`)
+)
+
+// StackFingerprint 计算调用栈的指纹, 去掉协程编号、参数和指令偏移, 同一处代码引发的panic指纹相同
+func StackFingerprint(stack string) string {
+	h := sha1.New()
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || goroutineLinePattern.MatchString(line) {
+			continue
+		}
+		if strings.HasPrefix(line, "created by ") {
+			line = createdByPattern.ReplaceAllString(line, "")
+		} else if isStackFileLine(line) {
+			line = stackOffsetPattern.ReplaceAllString(line, "")
+		} else {
+			// 函数行末尾括号中的参数每次都不一样, 比如 ({0x9c1ca0?, 0xa4f930?})、(0x1ac74a535af0?)、(...)
+			line = stackArgsPattern.ReplaceAllString(line, "()")
+		}
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isStackFileLine 判断是否是调用栈中的文件位置行, 比如 /app/main.go:12 +0x1d
+func isStackFileLine(line string) bool {
+	path, _, _ := strings.Cut(line, " ")
+	colon := strings.LastIndex(path, ":")
+	return colon > 0 && strings.HasSuffix(path[:colon], ".go")
+}
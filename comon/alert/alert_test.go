@@ -0,0 +1,110 @@
+package alert
+
+import (
+	"runtime/debug"
+	"sync"
+	"testing"
+)
+
+type stackProbe struct{}
+
+func (p *stackProbe) explode(v any, _ []int) {
+	panic(v)
+}
+
+// capturePanicStack 在新的协程中触发panic, 返回 recover 时的调用栈
+func capturePanicStack(v any) string {
+	var (
+		wg    sync.WaitGroup
+		stack string
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			recover()
+			stack = string(debug.Stack())
+		}()
+		(&stackProbe{}).explode(v, []int{1, 2, 3})
+	}()
+	wg.Wait()
+	return stack
+}
+
+func capturePanicStackElsewhere(v any) string {
+	var stack string
+	func() {
+		defer func() {
+			recover()
+			stack = string(debug.Stack())
+		}()
+		panic(v)
+	}()
+	return stack
+}
+
+func TestStackFingerprintRealStack(t *testing.T) {
+	first := capturePanicStack("boom")
+	second := capturePanicStack(&struct{ n int }{1})
+	if first == second {
+		t.Fatal("expected the raw stacks to differ in goroutine ids or argument addresses")
+	}
+	if StackFingerprint(first) != StackFingerprint(second) {
+		t.Errorf("same panic site got different fingerprints\n%s\n%s", first, second)
+	}
+	if StackFingerprint(first) == StackFingerprint(capturePanicStackElsewhere("boom")) {
+		t.Error("different panic sites got the same fingerprint")
+	}
+}
+
+func TestStackFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{
+			name: "goroutine id and offsets",
+			a:    "goroutine 7 [running]:\nmain.run()\n\t/app/main.go:7 +0x5d\n",
+			b:    "goroutine 42 [running]:\nmain.run()\n\t/app/main.go:7 +0x7f\n",
+			same: true,
+		},
+		{
+			name: "interface arguments",
+			a:    "panic({0x9c1ca0?, 0xa4f930?})\n\t/usr/local/go/src/runtime/panic.go:770 +0x132\n",
+			b:    "panic({0x55a6d0?, 0x4a4a28?})\n\t/usr/local/go/src/runtime/panic.go:770 +0x125\n",
+			same: true,
+		},
+		{
+			name: "pointer arguments and method receiver",
+			a:    "main.(*T).M(0x1ac74a535af0?, {0xc000012345, 0x3, 0x3})\n\t/app/main.go:6 +0x10\n",
+			b:    "main.(*T).M(...)\n\t/app/main.go:6\n",
+			same: true,
+		},
+		{
+			name: "created by goroutine",
+			a:    "created by main.main in goroutine 1\n\t/app/main.go:8 +0x7f\n",
+			b:    "created by main.main in goroutine 19\n\t/app/main.go:8 +0x7f\n",
+			same: true,
+		},
+		{
+			name: "different line",
+			a:    "main.run()\n\t/app/main.go:7 +0x5d\n",
+			b:    "main.run()\n\t/app/main.go:9 +0x5d\n",
+			same: false,
+		},
+		{
+			name: "different function",
+			a:    "main.run()\n\t/app/main.go:7 +0x5d\n",
+			b:    "main.walk()\n\t/app/main.go:7 +0x5d\n",
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StackFingerprint(tt.a) == StackFingerprint(tt.b); got != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github/lhh-gh/go-mall/comon/util/httptool"
+	"github/lhh-gh/go-mall/config"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookSink 把告警以JSON格式POST到Webhook地址
+type webhookSink struct {
+	url string
+}
+
+func NewWebhookSink(url string) Sink {
+	return &webhookSink{url: url}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Send(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, _, err = httptool.Post(ctx, s.url, body, httptool.WithTimeout(sendTimeout))
+	return err
+}
+
+// emailSink 通过SMTP发送告警邮件
+type emailSink struct {
+	option config.AlertEmail
+}
+
+func NewEmailSink(option config.AlertEmail) Sink {
+	return &emailSink{option: option}
+}
+
+func (s *emailSink) Name() string {
+	return "email"
+}
+
+func (s *emailSink) Send(ctx context.Context, event *Event) error {
+	subject := fmt.Sprintf("[%s][%s] %s", event.App, event.Env, event.Title)
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.option.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.option.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&b, "time: %s\r\n", event.OccurredAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "request: %s %s\r\n", event.Method, event.Path)
+	fmt.Fprintf(&b, "trace_id: %s\r\n", event.TraceId)
+	fmt.Fprintf(&b, "fingerprint: %s\r\n", event.Fingerprint)
+	fmt.Fprintf(&b, "suppressed: %d\r\n", event.Suppressed)
+	fmt.Fprintf(&b, "message: %s\r\n\r\n%s\r\n", event.Message, event.Stack)
+
+	addr := net.JoinHostPort(s.option.Host, strconv.Itoa(s.option.Port))
+	var auth smtp.Auth
+	if s.option.Username != "" {
+		auth = smtp.PlainAuth("", s.option.Username, s.option.Password, s.option.Host)
+	}
+	// smtp.SendMail 不支持 ctx, 放在协程里执行, 超时后不再等待结果
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, s.option.From, s.option.To, []byte(b.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fileSink 把告警按行写入本地文件, 每行是一个JSON
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+func (s *fileSink) Send(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
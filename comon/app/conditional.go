@@ -0,0 +1,129 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github/lhh-gh/go-mall/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 统一响应对 GET/HEAD 请求的条件请求支持:
+//   - ETag 默认对编码器输出的响应内容(不含 request_id)做摘要计算得到, 也可以通过 SetVersion 由数据的版本号生成
+//   - 计算摘要要多编码一次响应, 只在请求带有 If-None-Match 或者路由单独配置了缓存时计算, 其他请求不输出 ETag
+//   - SetLastModified 设置 Last-Modified, 请求头中没有 If-None-Match 时按 If-Modified-Since 判断
+//   - 客户端缓存仍然有效时响应 304, 不输出响应体
+//   - Cache-Control 按路由从配置 app.http_cache 中读取, 也可以通过 SetCacheControl 单独设置
+
+// SetVersion 用数据的版本号(比如version字段)生成ETag, 省去序列化data计算摘要
+func (r *response) SetVersion(version interface{}) *response {
+	r.etagSeed = fmt.Sprint(version)
+	return r
+}
+
+// SetLastModified 设置数据的最后修改时间, 一般传数据的 updated_at
+func (r *response) SetLastModified(updatedAt time.Time) *response {
+	r.lastModified = updatedAt
+	return r
+}
+
+// SetCacheControl 设置当前响应的 Cache-Control, 优先级高于配置
+func (r *response) SetCacheControl(cacheControl string) *response {
+	r.cacheControl = cacheControl
+	return r
+}
+
+// notModified 为GET/HEAD请求设置缓存相关的响应头, 客户端的缓存仍然有效时响应304并返回true
+func (r *response) notModified(encoder Encoder) bool {
+	req := r.ctx.Request
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if cacheControl := r.cacheControlValue(); cacheControl != "" {
+		r.ctx.Header("Cache-Control", cacheControl)
+	}
+	etag := ""
+	if r.etagNeeded() {
+		etag = r.etag(encoder)
+	}
+	if etag != "" {
+		r.ctx.Header("ETag", etag)
+	}
+	if !r.lastModified.IsZero() {
+		r.ctx.Header("Last-Modified", r.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	notModified := false
+	if ifNoneMatch := r.ctx.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		// 有 If-None-Match 时忽略 If-Modified-Since
+		notModified = etag != "" && etagMatch(ifNoneMatch, etag)
+	} else if ifModifiedSince := r.ctx.GetHeader("If-Modified-Since"); ifModifiedSince != "" && !r.lastModified.IsZero() {
+		if t, err := http.ParseTime(ifModifiedSince); err == nil {
+			notModified = !r.lastModified.Truncate(time.Second).After(t)
+		}
+	}
+	if notModified {
+		r.ctx.Status(http.StatusNotModified)
+		r.ctx.Writer.WriteHeaderNow()
+	}
+
+	return notModified
+}
+
+// etagNeeded 是否需要计算ETag, 设置了版本号时计算ETag没有额外开销
+// 没有单独配置缓存的路由, 客户端一般不会缓存响应, 只有带着 If-None-Match 来验证时才需要计算
+func (r *response) etagNeeded() bool {
+	if r.etagSeed != "" || r.cacheControl != "" || r.ctx.GetHeader("If-None-Match") != "" {
+		return true
+	}
+	_, ok := r.routeCacheControl()
+	return ok
+}
+
+// etag 生成强校验的ETag, 同一份数据的不同编码格式是不同的表示, 所以摘要中包含编码格式
+// 没有设置版本号时对编码器输出的内容做摘要, request_id 每次请求都不同, 不参与摘要计算
+func (r *response) etag(encoder Encoder) string {
+	h := sha256.New()
+	h.Write([]byte(encoder.ContentType()))
+	if r.etagSeed != "" {
+		h.Write([]byte(r.etagSeed))
+	} else {
+		snapshot := *r
+		snapshot.RequestId = ""
+		if err := encoder.Encode(h, &snapshot); err != nil {
+			return ""
+		}
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+func (r *response) cacheControlValue() string {
+	if r.cacheControl != "" {
+		return r.cacheControl
+	}
+	if cacheControl, ok := r.routeCacheControl(); ok {
+		return cacheControl
+	}
+	return config.App.HttpCache.CacheControl
+}
+
+// routeCacheControl 配置 app.http_cache.routes 中为当前路由单独设置的 Cache-Control
+func (r *response) routeCacheControl() (string, bool) {
+	cacheControl, ok := config.App.HttpCache.Routes[strings.ToLower(r.ctx.FullPath())]
+	return cacheControl, ok
+}
+
+// etagMatch 按弱比较的规则判断 If-None-Match 中是否有匹配的ETag
+func etagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/config"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+type conditionalTestGoods struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// newConditionalEngine /goods/cached 单独配置了缓存, /goods 使用默认配置, /goods/versioned 通过版本号生成ETag
+func newConditionalEngine(t *testing.T) *gin.Engine {
+	origin := config.App.HttpCache.Routes
+	config.App.HttpCache.Routes = map[string]string{"/goods/cached": "public, max-age=60"}
+	t.Cleanup(func() { config.App.HttpCache.Routes = origin })
+
+	requestId := 0
+	goods := &conditionalTestGoods{Id: 1, Name: "goods"}
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		// 每个请求的 request_id 都不同, 不能影响ETag
+		requestId++
+		c.Set("traceid", strconv.Itoa(requestId))
+	})
+	engine.GET("/goods", func(c *gin.Context) { NewResponse(c).Success(goods) })
+	engine.GET("/goods/cached", func(c *gin.Context) { NewResponse(c).Success(goods) })
+	engine.GET("/goods/versioned", func(c *gin.Context) { NewResponse(c).SetVersion(3).Success(goods) })
+	return engine
+}
+
+func doConditionalRequest(engine *gin.Engine, target, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestConditionalETag(t *testing.T) {
+	engine := newConditionalEngine(t)
+	tests := []struct {
+		name     string
+		target   string
+		wantETag bool
+	}{
+		{name: "default route skips etag", target: "/goods"},
+		{name: "route with cache config", target: "/goods/cached", wantETag: true},
+		{name: "versioned response", target: "/goods/versioned", wantETag: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := doConditionalRequest(engine, tt.target, "")
+			etag := first.Header().Get("ETag")
+			if (etag != "") != tt.wantETag {
+				t.Fatalf("ETag = %q, want present %v", etag, tt.wantETag)
+			}
+			if !tt.wantETag {
+				return
+			}
+			if second := doConditionalRequest(engine, tt.target, ""); second.Header().Get("ETag") != etag {
+				t.Errorf("ETag changed between requests: %q and %q", etag, second.Header().Get("ETag"))
+			}
+			if w := doConditionalRequest(engine, tt.target, etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Errorf("revalidate = %d with %d bytes body, want 304 without body", w.Code, w.Body.Len())
+			}
+			if w := doConditionalRequest(engine, tt.target+"?format=msgpack", etag); w.Code != http.StatusOK {
+				t.Errorf("revalidate another format = %d, want 200", w.Code)
+			}
+		})
+	}
+}
+
+func TestConditionalETagWithIfNoneMatch(t *testing.T) {
+	// 默认路由不输出ETag, 但客户端带着 If-None-Match 来验证时仍然按摘要比较
+	engine := newConditionalEngine(t)
+	cached := doConditionalRequest(engine, "/goods", `"stale"`)
+	etag := cached.Header().Get("ETag")
+	if cached.Code != http.StatusOK || etag == "" {
+		t.Fatalf("response = %d etag %q, want 200 with etag", cached.Code, etag)
+	}
+	if w := doConditionalRequest(engine, "/goods", etag); w.Code != http.StatusNotModified {
+		t.Errorf("revalidate = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 统一响应的内容协商:
+//   1. 优先使用查询参数 format 指定的格式, 比如 ?format=msgpack
+//   2. 其次按请求头 Accept 中的媒体类型(按q值排序)匹配已注册的编码器
+//   3. 都匹配不上时使用JSON
+// 项目内置 json、msgpack、protobuf、csv 四种编码器, 其他格式可通过 RegisterEncoder 注册
+
+// 内置的响应格式
+const (
+	FormatJSON     = "json"
+	FormatMsgPack  = "msgpack"
+	FormatProtobuf = "protobuf"
+	FormatCSV      = "csv"
+)
+
+const formatQueryKey = "format"
+
+// Encoder 响应编码器, Encode 的参数v是统一响应结构体
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+// dataOnlyEncoder 只能输出data部分的编码器(比如CSV)实现该接口, 这类编码器无法承载错误信息, 错误响应会回退到JSON
+type dataOnlyEncoder interface {
+	DataOnly() bool
+}
+
+type encoderEntry struct {
+	format    string
+	encoder   Encoder
+	mimeTypes []string
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = make(map[string]*encoderEntry) // format => encoder
+	mimeIndex  = make(map[string]*encoderEntry) // mime type => encoder
+)
+
+func init() {
+	RegisterEncoder(FormatJSON, jsonEncoder{}, "application/json", "text/json")
+	RegisterEncoder(FormatMsgPack, msgPackEncoder{}, "application/msgpack", "application/x-msgpack")
+	RegisterEncoder(FormatProtobuf, protobufEncoder{}, "application/x-protobuf", "application/protobuf")
+	RegisterEncoder(FormatCSV, csvEncoder{}, "text/csv")
+}
+
+// RegisterEncoder 注册响应编码器, format 用于匹配 format 查询参数, mimeTypes 用于匹配 Accept 请求头
+// 重复注册同一个 format 会覆盖之前的编码器
+func RegisterEncoder(format string, encoder Encoder, mimeTypes ...string) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	entry := &encoderEntry{format: format, encoder: encoder, mimeTypes: mimeTypes}
+	encoders[format] = entry
+	for _, mimeType := range mimeTypes {
+		mimeIndex[strings.ToLower(mimeType)] = entry
+	}
+}
+
+// negotiateEncoder 根据请求选择响应编码器
+// 响应的格式随请求头 Accept 变化, 要设置 Vary: Accept, 否则共享缓存可能把CSV、protobuf格式的响应返回给要JSON的客户端
+func negotiateEncoder(c *gin.Context) Encoder {
+	addVary(c.Writer.Header(), "Accept")
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	if format := c.Query(formatQueryKey); format != "" {
+		if entry, ok := encoders[strings.ToLower(format)]; ok {
+			return entry.encoder
+		}
+	}
+	for _, mimeType := range parseAccept(c.GetHeader("Accept")) {
+		if entry, ok := mimeIndex[mimeType]; ok {
+			return entry.encoder
+		}
+	}
+
+	return encoders[FormatJSON].encoder
+}
+
+// addVary 在响应头 Vary 中添加请求头, 已经存在时不重复添加
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// parseAccept 解析Accept请求头, 返回按q值从高到低排序的媒体类型
+func parseAccept(accept string) []string {
+	if accept == "" {
+		return nil
+	}
+	type acceptItem struct {
+		mimeType string
+		q        float64
+	}
+	items := make([]acceptItem, 0)
+	for _, part := range strings.Split(accept, ",") {
+		segments := strings.Split(part, ";")
+		mimeType := strings.ToLower(strings.TrimSpace(segments[0]))
+		if mimeType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range segments[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			items = append(items, acceptItem{mimeType: mimeType, q: q})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+	mimeTypes := make([]string, 0, len(items))
+	for _, item := range items {
+		mimeTypes = append(mimeTypes, item.mimeType)
+	}
+
+	return mimeTypes
+}
+
+// encoderRender 把编码器适配成 gin 的 render.Render
+type encoderRender struct {
+	encoder Encoder
+	data    interface{}
+}
+
+func (er encoderRender) Render(w http.ResponseWriter) error {
+	er.WriteContentType(w)
+	return er.encoder.Encode(w, er.data)
+}
+
+func (er encoderRender) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	if val := header["Content-Type"]; len(val) == 0 {
+		header["Content-Type"] = []string{er.encoder.ContentType()}
+	}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonBytes)
+	return err
+}
+
+// msgPackHandle 未设置codec标签的字段使用json标签作为字段名, 与JSON响应保持一致
+var msgPackHandle = func() *codec.MsgpackHandle {
+	h := new(codec.MsgpackHandle)
+	h.WriteExt = true
+	h.Canonical = true
+	return h
+}()
+
+type msgPackEncoder struct{}
+
+func (msgPackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgPackEncoder) Encode(w io.Writer, v interface{}) error {
+	return codec.NewEncoder(w, msgPackHandle).Encode(v)
+}
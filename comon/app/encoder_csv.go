@@ -0,0 +1,260 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// csvEncoder 只输出统一响应中的data部分, data 为切片时每个元素一行, 其他类型输出为单行
+// 列名取字段的json标签, map 类型的元素按key排序生成列
+type csvEncoder struct{}
+
+func (csvEncoder) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (csvEncoder) DataOnly() bool {
+	return true
+}
+
+func (csvEncoder) Encode(w io.Writer, v interface{}) error {
+	data := v
+	if resp, ok := v.(*response); ok {
+		data = resp.Data
+	}
+	rows := csvRowValues(data)
+	if len(rows) == 0 {
+		return nil
+	}
+	schema, err := newCSVSchema(rows[0])
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err = cw.Write(schema.columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err = cw.Write(schema.record(row)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CSVPageFetcher 按分页读取要导出的数据, 返回的 rows 为切片, 返回空切片时导出结束
+type CSVPageFetcher func(page *pagination) (rows interface{}, err error)
+
+// StreamCSV 分页读取数据并以流的方式输出CSV, 每读取一页就写出并Flush一次, 用于大数据量的列表导出
+// 已经开始输出后再发生的错误只能记录日志并中断输出
+func (r *response) StreamCSV(filename string, fetch CSVPageFetcher) {
+	page := r.Pagination
+	if page == nil {
+		page = NewPagination(r.ctx)
+	}
+	if page.PageSize <= 0 {
+		// 按返回的行数少于 PageSize 判断导出结束, PageSize 不能为0
+		r.Error(errcode.ErrParams.WithDetail(errors.New("stream csv: page size must be positive")))
+		return
+	}
+	log := logger.New(r.ctx)
+	var (
+		cw     *csv.Writer
+		schema *csvSchema
+	)
+	for {
+		select {
+		case <-r.ctx.Request.Context().Done():
+			// 客户端断开连接后不再继续读取数据
+			return
+		default:
+		}
+
+		data, err := fetch(page)
+		if err != nil {
+			if cw == nil {
+				r.Error(errcode.ErrServer.WithCause(err))
+				return
+			}
+			log.Error("stream_csv_error", "page", page.Page, "err", err)
+			return
+		}
+		rows := csvRowValues(data)
+		if cw == nil {
+			if len(rows) > 0 {
+				if schema, err = newCSVSchema(rows[0]); err != nil {
+					r.Error(errcode.ErrServer.WithCause(err))
+					return
+				}
+			}
+			r.ctx.Header("Content-Type", csvEncoder{}.ContentType())
+			r.ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+			r.ctx.Header("X-Request-Id", r.requestId())
+			r.ctx.Status(http.StatusOK)
+			cw = csv.NewWriter(r.ctx.Writer)
+			if schema != nil {
+				if err = cw.Write(schema.columns); err != nil {
+					log.Error("stream_csv_error", "page", page.Page, "err", err)
+					return
+				}
+			}
+		}
+		if err = writeCSVRows(cw, schema, rows); err != nil {
+			log.Error("stream_csv_error", "page", page.Page, "err", err)
+			return
+		}
+		r.ctx.Writer.Flush()
+		if len(rows) < page.PageSize {
+			return
+		}
+		page.Page++
+	}
+}
+
+// writeCSVRows 写出一页数据并 Flush, 返回写出过程中的错误(比如客户端断开连接)
+func writeCSVRows(cw *csv.Writer, schema *csvSchema, rows []reflect.Value) error {
+	for _, row := range rows {
+		if err := cw.Write(schema.record(row)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRowValues 把data展开成行
+func csvRowValues(data interface{}) []reflect.Value {
+	if data == nil {
+		return nil
+	}
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		rows := make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, indirectValue(v.Index(i)))
+		}
+		return rows
+	case reflect.String:
+		if v.Len() == 0 {
+			return nil
+		}
+	}
+
+	return []reflect.Value{v}
+}
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// csvSchema 由第一行数据确定的CSV列
+type csvSchema struct {
+	columns []string
+	fields  [][]int // 结构体元素每列对应的字段索引, map 元素为nil
+	isMap   bool
+}
+
+func newCSVSchema(first reflect.Value) (*csvSchema, error) {
+	switch first.Kind() {
+	case reflect.Struct:
+		s := &csvSchema{fields: make([][]int, 0)}
+		t := first.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				tagName := strings.Split(tag, ",")[0]
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+			s.columns = append(s.columns, name)
+			s.fields = append(s.fields, f.Index)
+		}
+		return s, nil
+	case reflect.Map:
+		if first.Type().Key().Kind() != reflect.String {
+			return nil, errors.New("csv: map rows must have string keys")
+		}
+		s := &csvSchema{isMap: true}
+		for _, key := range first.MapKeys() {
+			s.columns = append(s.columns, fmt.Sprint(key.Interface()))
+		}
+		sort.Strings(s.columns)
+		return s, nil
+	case reflect.Invalid:
+		return nil, errors.New("csv: invalid row")
+	default:
+		return &csvSchema{columns: []string{"value"}}, nil
+	}
+}
+
+func (s *csvSchema) record(row reflect.Value) []string {
+	record := make([]string, len(s.columns))
+	if !row.IsValid() {
+		return record
+	}
+	switch {
+	case s.fields != nil && row.Kind() == reflect.Struct:
+		for i, index := range s.fields {
+			record[i] = csvCell(row.FieldByIndex(index))
+		}
+	case s.isMap && row.Kind() == reflect.Map:
+		for i, column := range s.columns {
+			record[i] = csvCell(row.MapIndex(reflect.ValueOf(column).Convert(row.Type().Key())))
+		}
+	case s.fields == nil && !s.isMap:
+		record[0] = csvCell(row)
+	}
+
+	return record
+}
+
+// csvCell 把单元格的值格式化成字符串, 时间使用项目统一的时间格式, 复合类型输出为JSON
+func csvCell(v reflect.Value) string {
+	v = indirectValue(v)
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return ""
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(enum.TimeFormatHyphenedYMDHIS)
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		b, _ := json.Marshal(v.Interface())
+		return string(b)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"io"
+)
+
+// protobufEncoder 按下面的消息定义编码统一响应, 调用方可以用这个定义生成对应语言的代码
+//
+//	message Envelope {
+//	  int64 code = 1;
+//	  string msg = 2;
+//	  string request_id = 3;
+//	  google.protobuf.Any data = 4;
+//	  Pagination pagination = 5;
+//	  string detail = 6;
+//	}
+//
+//	message Pagination {
+//	  int64 page = 1;
+//	  int64 page_size = 2;
+//	  int64 total_rows = 3;
+//	}
+//
+// data 本身是 proto.Message 时直接打包进 Any, 其他类型的 data 先转成 google.protobuf.Value 再打包
+type protobufEncoder struct{}
+
+func (protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protobufEncoder) Encode(w io.Writer, v interface{}) error {
+	resp, ok := v.(*response)
+	if !ok {
+		return errors.New("protobuf encoder: unsupported value")
+	}
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(resp.Code))
+	b = appendProtoString(b, 2, resp.Msg)
+	b = appendProtoString(b, 3, resp.RequestId)
+	if resp.Data != nil {
+		data, err := protoAnyData(resp.Data)
+		if err != nil {
+			return err
+		}
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, data)
+	}
+	if p := resp.Pagination; p != nil {
+		var pb []byte
+		pb = protowire.AppendTag(pb, 1, protowire.VarintType)
+		pb = protowire.AppendVarint(pb, uint64(p.Page))
+		pb = protowire.AppendTag(pb, 2, protowire.VarintType)
+		pb = protowire.AppendVarint(pb, uint64(p.PageSize))
+		pb = protowire.AppendTag(pb, 3, protowire.VarintType)
+		pb = protowire.AppendVarint(pb, uint64(p.TotalRows))
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, pb)
+	}
+	b = appendProtoString(b, 6, resp.Detail)
+
+	_, err := w.Write(b)
+	return err
+}
+
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// protoAnyData 把 data 打包成序列化后的 google.protobuf.Any
+func protoAnyData(data interface{}) ([]byte, error) {
+	msg, ok := data.(proto.Message)
+	if !ok {
+		// 借助JSON把任意结构转换成 google.protobuf.Value, 字段名与JSON响应保持一致
+		jsonBytes, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		value := new(structpb.Value)
+		if err = value.UnmarshalJSON(jsonBytes); err != nil {
+			return nil, err
+		}
+		msg = value
+	}
+	anyData, err := anypb.New(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(anyData)
+}
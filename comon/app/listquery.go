@@ -0,0 +1,241 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 列表接口的排序和过滤查询语法:
+//   排序: sort=-created_at,id  字段前加 "-" 表示降序, 多个字段用逗号分隔
+//   过滤: state=2 等值过滤, created_at[gte]=2024-01-01 00:00:00 带操作符的过滤
+// 每个接口通过 QueryWhitelist 声明允许排序/过滤的字段和字段对应的数据库列, 白名单之外的字段会返回参数错误
+
+// 支持的过滤操作符
+const (
+	FilterOpEq   = "eq"
+	FilterOpNe   = "ne"
+	FilterOpGt   = "gt"
+	FilterOpGte  = "gte"
+	FilterOpLt   = "lt"
+	FilterOpLte  = "lte"
+	FilterOpIn   = "in"   // 多个值用逗号分隔 state[in]=1,2
+	FilterOpLike = "like" // 模糊匹配 order_no[like]=2024, 值中的 % 和 _ 按普通字符匹配
+)
+
+// in 操作符最多允许的值的个数, 避免生成过长的SQL
+const maxInValues = 100
+
+// likeEscapeChar like 过滤中转义通配符使用的字符, 不使用反斜杠, 避免受 MySQL 的 NO_BACKSLASH_ESCAPES 模式影响
+const likeEscapeChar = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscapeChar, likeEscapeChar+likeEscapeChar, "%", likeEscapeChar+"%", "_", likeEscapeChar+"_")
+
+// 排序参数名和单次请求最多允许的排序字段数
+const (
+	sortQueryKey  = "sort"
+	maxSortFields = 5
+)
+
+var filterKeyPattern = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)\[([a-z]+)\]$`)
+
+// QueryField 列表接口中一个可排序或可过滤的字段
+type QueryField struct {
+	Column    string   // 字段映射的数据库列名, 为空时使用字段名
+	Sortable  bool     // 是否允许按该字段排序
+	Operators []string // 允许使用的过滤操作符, 为空表示不允许过滤
+}
+
+// QueryWhitelist 接口的排序/过滤字段白名单, key 为请求中使用的字段名
+type QueryWhitelist map[string]QueryField
+
+type sortItem struct {
+	column string
+	desc   bool
+}
+
+type filterItem struct {
+	column string
+	op     string
+	value  string
+}
+
+type listQuery struct {
+	sorts   []sortItem
+	filters []filterItem
+}
+
+// NewListQuery 按白名单解析请求中的排序和过滤参数
+// 不带操作符且不在白名单中的查询参数(比如 page、page_size)会被忽略,
+// 使用了白名单之外的排序字段、带操作符的过滤字段、不允许的操作符、重复传递排序/过滤参数, 或者 in 的值中有空值、超过 maxInValues 个时返回 ErrParams,
+// 具体哪个参数不合法会通过响应的 detail 返回给调用方
+func NewListQuery(c *gin.Context, whitelist QueryWhitelist) (*listQuery, *errcode.AppError) {
+	q := &listQuery{}
+	query := c.Request.URL.Query()
+	// 按参数名排序后解析, 同样的请求总是生成同样顺序的过滤条件, 方便复用SQL和排查问题
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var err error
+		if key == sortQueryKey {
+			err = q.parseSort(query[key], whitelist)
+		} else {
+			err = q.parseFilter(key, query[key], whitelist)
+		}
+		if err != nil {
+			return nil, errcode.ErrParams.WithDetail(err)
+		}
+	}
+
+	return q, nil
+}
+
+func (q *listQuery) parseSort(values []string, whitelist QueryWhitelist) error {
+	if len(values) > 1 {
+		return errors.New("sort: parameter is repeated, use comma separated fields instead")
+	}
+	if len(values) == 0 || values[0] == "" {
+		return nil
+	}
+	fields := strings.Split(values[0], ",")
+	if len(fields) > maxSortFields {
+		return fmt.Errorf("sort: at most %d fields are allowed", maxSortFields)
+	}
+	for _, name := range fields {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if name == "" {
+			return errors.New("sort: empty field")
+		}
+		field, ok := whitelist[name]
+		if !ok || !field.Sortable {
+			return fmt.Errorf("sort: field %q is not sortable", name)
+		}
+		q.sorts = append(q.sorts, sortItem{column: field.column(name), desc: desc})
+	}
+
+	return nil
+}
+
+func (q *listQuery) parseFilter(key string, values []string, whitelist QueryWhitelist) error {
+	name, op := key, FilterOpEq
+	matches := filterKeyPattern.FindStringSubmatch(key)
+	if matches != nil {
+		name, op = matches[1], matches[2]
+	}
+	field, ok := whitelist[name]
+	if !ok {
+		if matches == nil {
+			// 普通查询参数, 不参与过滤
+			return nil
+		}
+		return fmt.Errorf("filter: field %q is not filterable", name)
+	}
+	if !field.allowOperator(op) {
+		return fmt.Errorf("filter: operator %q is not allowed on field %q", op, name)
+	}
+	if len(values) > 1 {
+		// 多个值请使用 in 操作符, 不静默丢弃多余的值
+		return fmt.Errorf("filter: parameter %q is repeated", key)
+	}
+	var value string
+	if len(values) == 1 {
+		value = values[0]
+	}
+	if value == "" {
+		return fmt.Errorf("filter: empty value for field %q", name)
+	}
+	if op == FilterOpIn {
+		items := strings.Split(value, ",")
+		if len(items) > maxInValues {
+			return fmt.Errorf("filter: at most %d values are allowed for field %q", maxInValues, name)
+		}
+		for _, v := range items {
+			if strings.TrimSpace(v) == "" {
+				return fmt.Errorf("filter: empty value in list for field %q", name)
+			}
+		}
+	}
+	q.filters = append(q.filters, filterItem{column: field.column(name), op: op, value: value})
+
+	return nil
+}
+
+// Scopes 把解析出的排序和过滤条件转换成 gorm scopes, 在 DAO 中通过 DB().Scopes(q.Scopes()...) 使用
+func (q *listQuery) Scopes() []func(*gorm.DB) *gorm.DB {
+	scopes := make([]func(*gorm.DB) *gorm.DB, 0, len(q.filters)+1)
+	for _, f := range q.filters {
+		scopes = append(scopes, f.scope())
+	}
+	if len(q.sorts) > 0 {
+		sorts := q.sorts
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB {
+			for _, s := range sorts {
+				db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: s.column}, Desc: s.desc})
+			}
+			return db
+		})
+	}
+
+	return scopes
+}
+
+func (f filterItem) scope() func(*gorm.DB) *gorm.DB {
+	column := clause.Column{Name: f.column}
+	var expr clause.Expression
+	switch f.op {
+	case FilterOpNe:
+		expr = clause.Neq{Column: column, Value: f.value}
+	case FilterOpGt:
+		expr = clause.Gt{Column: column, Value: f.value}
+	case FilterOpGte:
+		expr = clause.Gte{Column: column, Value: f.value}
+	case FilterOpLt:
+		expr = clause.Lt{Column: column, Value: f.value}
+	case FilterOpLte:
+		expr = clause.Lte{Column: column, Value: f.value}
+	case FilterOpIn:
+		values := make([]interface{}, 0)
+		for _, v := range strings.Split(f.value, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+		expr = clause.IN{Column: column, Values: values}
+	case FilterOpLike:
+		expr = clause.Expr{
+			SQL:  "? LIKE ? ESCAPE '" + likeEscapeChar + "'",
+			Vars: []interface{}{column, "%" + likeEscaper.Replace(f.value) + "%"},
+		}
+	default:
+		expr = clause.Eq{Column: column, Value: f.value}
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(expr)
+	}
+}
+
+func (f QueryField) column(name string) string {
+	if f.Column != "" {
+		return f.Column
+	}
+	return name
+}
+
+func (f QueryField) allowOperator(op string) bool {
+	for _, allowed := range f.Operators {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testWhitelist = QueryWhitelist{
+	"id":         {Sortable: true},
+	"created_at": {Column: "created_at", Sortable: true, Operators: []string{FilterOpGte, FilterOpLte}},
+	"state":      {Operators: []string{FilterOpEq, FilterOpIn}},
+	"order_no":   {Column: "order_no", Operators: []string{FilterOpLike}},
+}
+
+func newListQueryContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/list?"+rawQuery, nil)
+	return c
+}
+
+func TestNewListQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantSorts   []sortItem
+		wantFilters []filterItem
+		wantErr     bool
+	}{
+		{
+			name:  "no sort and filter",
+			query: "page=1&page_size=10",
+		},
+		{
+			name:      "multiple sort fields",
+			query:     "sort=-created_at,id",
+			wantSorts: []sortItem{{column: "created_at", desc: true}, {column: "id"}},
+		},
+		{
+			name:  "filters are sorted by parameter name",
+			query: "state[in]=1,2&created_at[lte]=2024-12-31&created_at[gte]=2024-01-01&order_no[like]=2024",
+			wantFilters: []filterItem{
+				{column: "created_at", op: FilterOpGte, value: "2024-01-01"},
+				{column: "created_at", op: FilterOpLte, value: "2024-12-31"},
+				{column: "order_no", op: FilterOpLike, value: "2024"},
+				{column: "state", op: FilterOpIn, value: "1,2"},
+			},
+		},
+		{
+			name:        "equal filter without operator",
+			query:       "state=2",
+			wantFilters: []filterItem{{column: "state", op: FilterOpEq, value: "2"}},
+		},
+		{name: "sort field not in whitelist", query: "sort=password", wantErr: true},
+		{name: "sort field not sortable", query: "sort=state", wantErr: true},
+		{name: "empty sort field", query: "sort=id,,created_at", wantErr: true},
+		{name: "too many sort fields", query: "sort=id,id,id,id,id,id", wantErr: true},
+		{name: "repeated sort parameter", query: "sort=id&sort=-created_at", wantErr: true},
+		{name: "filter field not in whitelist", query: "password[eq]=1", wantErr: true},
+		{name: "operator not allowed", query: "state[gt]=1", wantErr: true},
+		{name: "empty filter value", query: "state=", wantErr: true},
+		{name: "repeated filter parameter", query: "state=1&state=2", wantErr: true},
+		{name: "empty value in list", query: "state[in]=1,,2", wantErr: true},
+		{name: "blank value in list", query: "state[in]=1,%20", wantErr: true},
+		{name: "too many values in list", query: "state[in]=" + strings.Repeat("1,", maxInValues) + "1", wantErr: true},
+		{
+			name:        "max values in list",
+			query:       "state[in]=" + strings.Repeat("1,", maxInValues-1) + "1",
+			wantFilters: []filterItem{{column: "state", op: FilterOpIn, value: strings.Repeat("1,", maxInValues-1) + "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, appErr := NewListQuery(newListQueryContext(tt.query), testWhitelist)
+			if tt.wantErr {
+				if appErr == nil {
+					t.Fatalf("NewListQuery(%q) error = nil, want ErrParams", tt.query)
+				}
+				if appErr.Code() != errcode.ErrParams.Code() || appErr.Detail() == "" {
+					t.Fatalf("NewListQuery(%q) error = %v, want ErrParams with detail", tt.query, appErr)
+				}
+				return
+			}
+			if appErr != nil {
+				t.Fatalf("NewListQuery(%q) unexpected error: %v", tt.query, appErr)
+			}
+			if !reflect.DeepEqual(q.sorts, tt.wantSorts) {
+				t.Errorf("sorts = %+v, want %+v", q.sorts, tt.wantSorts)
+			}
+			if !reflect.DeepEqual(q.filters, tt.wantFilters) {
+				t.Errorf("filters = %+v, want %+v", q.filters, tt.wantFilters)
+			}
+		})
+	}
+}
+
+func TestNewListQueryKeepsErrParamsUntouched(t *testing.T) {
+	if _, appErr := NewListQuery(newListQueryContext("sort=password"), testWhitelist); appErr == nil {
+		t.Fatal("NewListQuery() error = nil, want ErrParams")
+	}
+	if errcode.ErrParams.Detail() != "" || errcode.ErrParams.Unwrap() != nil {
+		t.Fatalf("predefined ErrParams was modified: %v", errcode.ErrParams)
+	}
+}
+
+type listQueryTestOrder struct {
+	Id      int64
+	OrderNo string
+}
+
+func TestListQueryLikeEscapesWildcards(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "list.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open db error: %v", err)
+	}
+	if err = db.AutoMigrate(&listQueryTestOrder{}); err != nil {
+		t.Fatalf("migrate error: %v", err)
+	}
+	for _, orderNo := range []string{"50%off", "500", "a_b", "axb", "x!y", "x!!y"} {
+		db.Create(&listQueryTestOrder{OrderNo: orderNo})
+	}
+
+	tests := []struct {
+		like string
+		want []string
+	}{
+		{like: "%", want: []string{"50%off"}},
+		{like: "_", want: []string{"a_b"}},
+		{like: "a_b", want: []string{"a_b"}},
+		{like: "!", want: []string{"x!y", "x!!y"}},
+		{like: "!!", want: []string{"x!!y"}},
+		{like: "50", want: []string{"50%off", "500"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.like, func(t *testing.T) {
+			q, appErr := NewListQuery(newListQueryContext("order_no[like]="+url.QueryEscape(tt.like)), testWhitelist)
+			if appErr != nil {
+				t.Fatalf("NewListQuery() error: %v", appErr)
+			}
+			got := make([]string, 0)
+			if err := db.Model(&listQueryTestOrder{}).Scopes(q.Scopes()...).Order("id").Pluck("order_no", &got).Error; err != nil {
+				t.Fatalf("query error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order_no[like]=%s matched %v, want %v", tt.like, got, tt.want)
+			}
+		})
+	}
+}
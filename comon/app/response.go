@@ -4,6 +4,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github/lhh-gh/go-mall/comon/errcode"
 	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"strconv"
+	"time"
 )
 
 // response 统一返回结构体
@@ -11,9 +14,15 @@ type response struct {
 	ctx        *gin.Context
 	Code       int         `json:"code"`
 	Msg        string      `json:"msg"`
+	Detail     string      `json:"detail,omitempty"`
 	RequestId  string      `json:"request_id"`
 	Data       interface{} `json:"data,omitempty"`       //omitempty: 忽略空值
 	Pagination *pagination `json:"pagination,omitempty"` //
+
+	// 条件请求和缓存相关的设置, 不参与响应体的编码
+	etagSeed     string
+	lastModified time.Time
+	cacheControl string
 }
 
 // NewResponse 创建新的响应实例
@@ -39,15 +48,14 @@ func (r *response) SetPagination(pagination *pagination) *response {
 func (r *response) Success(data interface{}) {
 	r.Code = errcode.Success.Code()
 	r.Msg = errcode.Success.Msg()
-	requestId := ""
-	if _, exists := r.ctx.Get("traceid"); exists {
-		val, _ := r.ctx.Get("traceid")
-		requestId = val.(string)
-	}
-	r.RequestId = requestId
+	r.RequestId = r.requestId()
 	r.Data = data
 
-	r.ctx.JSON(errcode.Success.HttpStatusCode(), r)
+	encoder := negotiateEncoder(r.ctx)
+	if r.notModified(encoder) {
+		return
+	}
+	r.render(errcode.Success.HttpStatusCode(), encoder)
 }
 
 func (r *response) SuccessOk() {
@@ -57,13 +65,27 @@ func (r *response) SuccessOk() {
 func (r *response) Error(err *errcode.AppError) {
 	r.Code = err.Code()
 	r.Msg = err.Msg()
-	requestId := ""
-	if _, exists := r.ctx.Get("traceid"); exists {
-		val, _ := r.ctx.Get("traceid")
-		requestId = val.(string)
-	}
-	r.RequestId = requestId
+	r.Detail = err.Detail()
+	r.RequestId = r.requestId()
 	// 兜底记一条响应错误, 项目自定义的AppError中有错误链条, 方便出错后排查问题
 	logger.New(r.ctx).Error("api_response_error", "err", err)
-	r.ctx.JSON(err.HttpStatusCode(), r)
+	metrics.BusinessErrorsTotal.WithLabelValues(strconv.Itoa(err.Code())).Inc()
+	encoder := negotiateEncoder(r.ctx)
+	if dataOnly, ok := encoder.(dataOnlyEncoder); ok && dataOnly.DataOnly() {
+		// CSV这类只能输出data的格式承载不了错误信息, 错误响应回退到JSON
+		encoder = jsonEncoder{}
+	}
+	r.render(err.HttpStatusCode(), encoder)
+}
+
+// render 使用协商出的编码器输出统一响应
+func (r *response) render(httpStatusCode int, encoder Encoder) {
+	r.ctx.Render(httpStatusCode, encoderRender{encoder: encoder, data: r})
+}
+
+func (r *response) requestId() string {
+	if val, exists := r.ctx.Get("traceid"); exists {
+		return val.(string)
+	}
+	return ""
 }
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Server-Sent Events 推送, 用于向客户端推送进度、状态变化等消息
+// 每个事件的 data 是一行JSON, 字段与统一响应保持一致: {"code":0,"msg":"success","request_id":"...","data":{...}}
+
+// DefaultSSEHeartbeat 默认的心跳间隔, 防止连接因为长时间没有数据被网关或者代理断开
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// SSEEvent 推送给客户端的事件
+type SSEEvent struct {
+	Id    string            // 事件ID, 客户端断线重连时会通过 Last-Event-ID 请求头带回最后收到的ID
+	Event string            // 事件类型, 为空时客户端按 message 事件处理
+	Data  interface{}       // 业务数据, 放在统一响应结构的data字段中
+	Err   *errcode.AppError // 不为空时推送错误信息, 此时忽略Data
+	Retry time.Duration     // 告诉客户端断线后的重连间隔, 为0时不设置
+}
+
+type sseStream struct {
+	ctx       *gin.Context
+	requestId string
+	heartbeat time.Duration
+}
+
+// NewSSEStream 创建SSE推送, 创建后会立即写出响应头
+func NewSSEStream(c *gin.Context) *sseStream {
+	s := &sseStream{
+		ctx:       c,
+		requestId: NewResponse(c).requestId(),
+		heartbeat: DefaultSSEHeartbeat,
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 关闭 Nginx 的响应缓冲
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	return s
+}
+
+// SetHeartbeat 设置心跳间隔, 小于等于0时不发送心跳
+func (s *sseStream) SetHeartbeat(heartbeat time.Duration) *sseStream {
+	s.heartbeat = heartbeat
+	return s
+}
+
+// LastEventId 客户端断线重连时带回的最后一个事件ID, 用于从断点继续推送
+func (s *sseStream) LastEventId() string {
+	return s.ctx.GetHeader("Last-Event-ID")
+}
+
+// Done 客户端断开连接时关闭
+func (s *sseStream) Done() <-chan struct{} {
+	return s.ctx.Request.Context().Done()
+}
+
+// Stream 持续读取 events 推送给客户端, 直到 events 被关闭或者客户端断开连接
+// 没有事件可推送时按心跳间隔发送注释行, 客户端断开连接时返回 false
+func (s *sseStream) Stream(events <-chan *SSEEvent) bool {
+	var heartbeat <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		select {
+		case <-s.Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return true
+			}
+			if err := s.Send(event); err != nil {
+				return false
+			}
+		case <-heartbeat:
+			if err := s.write(": heartbeat\n\n"); err != nil {
+				return false
+			}
+		}
+	}
+}
+
+// Send 推送单个事件
+func (s *sseStream) Send(event *SSEEvent) error {
+	envelope := &response{RequestId: s.requestId}
+	if event.Err != nil {
+		envelope.Code = event.Err.Code()
+		envelope.Msg = event.Err.Msg()
+	} else {
+		envelope.Code = errcode.Success.Code()
+		envelope.Msg = errcode.Success.Msg()
+		envelope.Data = event.Data
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		logger.New(s.ctx).Error("sse_event_encode_error", "err", err)
+		return err
+	}
+
+	var b strings.Builder
+	if event.Id != "" {
+		fmt.Fprintf(&b, "id: %s\n", sseEscape(event.Id))
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", sseEscape(event.Event))
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+
+	return s.write(b.String())
+}
+
+func (s *sseStream) write(message string) error {
+	if _, err := s.ctx.Writer.WriteString(message); err != nil {
+		return err
+	}
+	s.ctx.Writer.Flush()
+	return nil
+}
+
+// sseEscape 事件ID和类型中不能出现换行
+func sseEscape(v string) string {
+	return strings.NewReplacer("\n", "", "\r", "").Replace(v)
+}
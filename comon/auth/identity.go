@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+// identityCtxKey 认证通过后用户身份在上下文中的Key, 与 traceid 一样使用字符串Key, gin.Context 和派生的 context 都能取到
+const identityCtxKey = "auth_identity"
+
+// Identity 通过Token认证的用户身份
+type Identity struct {
+	UserId      int64
+	SessionId   string    // 同一次登录签发和刷新出的Token属于同一个会话, 按会话吊销Token
+	TokenId     string    // Access Token 的 jti
+	ExpiresAt   time.Time // Access Token 的过期时间
+	AccessToken string    // 原始的 Access Token, 调用内部其他服务时透传
+}
+
+// SetIdentity 把用户身份放到gin上下文中
+func SetIdentity(c *gin.Context, identity *Identity) {
+	c.Set(identityCtxKey, identity)
+}
+
+// GetIdentity 从上下文中读取用户身份, 未经过认证中间件或认证未通过时返回 false
+func GetIdentity(ctx context.Context) (*Identity, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	identity, ok := ctx.Value(identityCtxKey).(*Identity)
+	return identity, ok && identity != nil
+}
+
+// GetUserId 从上下文中读取认证用户的ID, 未认证时返回0
+func GetUserId(ctx context.Context) int64 {
+	if identity, ok := GetIdentity(ctx); ok {
+		return identity.UserId
+	}
+	return 0
+}
+
+// BearerToken 读取请求头 Authorization: Bearer <token> 中的Token
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// TokenFingerprint 生成Token的指纹, 用于在日志中标识Token而不暴露Token本身
+func TokenFingerprint(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github/lhh-gh/go-mall/config"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access Token 使用JWT, 服务端只需验签不用查存储; Refresh Token 是随机串, 保存在 Redis 中, 可以随时吊销
+// 签名密钥支持轮换: 按配置中的 signing_key_id 签发, 验证时按Token头部的 kid 选择密钥
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // 为nil时只能用于验证
+	verifyKey interface{}
+}
+
+type accessClaims struct {
+	SessionId string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+const (
+	defaultAccessTokenTTL  = 2 * time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ErrAuthNotConfigured 配置中没有 auth 时不能签发和验证Token
+var ErrAuthNotConfigured = errors.New("auth is not configured")
+
+var (
+	keys     map[string]*signingKey
+	keysErr  error
+	keysOnce sync.Once
+)
+
+// loadKeys 加载配置中的签名密钥
+func loadKeys() (map[string]*signingKey, error) {
+	keysOnce.Do(func() {
+		if config.Auth == nil {
+			keysErr = ErrAuthNotConfigured
+			return
+		}
+		keys = make(map[string]*signingKey)
+		for _, keyConf := range config.Auth.Keys {
+			key, err := parseKey(keyConf)
+			if err != nil {
+				keysErr = fmt.Errorf("load jwt key %s: %w", keyConf.Id, err)
+				return
+			}
+			keys[key.id] = key
+		}
+	})
+	return keys, keysErr
+}
+
+func parseKey(keyConf config.JwtKey) (*signingKey, error) {
+	key := &signingKey{id: keyConf.Id}
+	switch keyConf.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if keyConf.Secret == "" {
+			return nil, errors.New("empty secret")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey, key.verifyKey = []byte(keyConf.Secret), []byte(keyConf.Secret)
+	case jwt.SigningMethodRS256.Alg():
+		key.method = jwt.SigningMethodRS256
+		pubPem, err := os.ReadFile(keyConf.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pubPem); err != nil {
+			return nil, err
+		}
+		if keyConf.PrivateKeyFile != "" {
+			privPem, err := os.ReadFile(keyConf.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.signKey, err = jwt.ParseRSAPrivateKeyFromPEM(privPem); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", keyConf.Algorithm)
+	}
+
+	return key, nil
+}
+
+// SignAccessToken 签发 Access Token, 返回Token和它的ID(jti)
+func SignAccessToken(userId int64, sessionId string, expiresAt time.Time) (token, tokenId string, err error) {
+	keySet, err := loadKeys()
+	if err != nil {
+		return "", "", err
+	}
+	key, ok := keySet[config.Auth.SigningKeyId]
+	if !ok || key.signKey == nil {
+		return "", "", fmt.Errorf("jwt signing key %q is not available", config.Auth.SigningKeyId)
+	}
+	tokenId = RandomToken(16)
+	now := time.Now()
+	claims := &accessClaims{
+		SessionId: sessionId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.Auth.Issuer,
+			Subject:   strconv.FormatInt(userId, 10),
+			ID:        tokenId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	jwtToken := jwt.NewWithClaims(key.method, claims)
+	jwtToken.Header["kid"] = key.id
+	token, err = jwtToken.SignedString(key.signKey)
+	return token, tokenId, err
+}
+
+// ParseAccessToken 验证 Access Token 并解析出用户身份
+func ParseAccessToken(token string) (*Identity, error) {
+	keySet, err := loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	claims := new(accessClaims)
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keySet[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", t.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(config.Auth.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	userId, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject %q", claims.Subject)
+	}
+
+	return &Identity{
+		UserId:      userId,
+		SessionId:   claims.SessionId,
+		TokenId:     claims.ID,
+		ExpiresAt:   claims.ExpiresAt.Time,
+		AccessToken: token,
+	}, nil
+}
+
+// AccessTokenTTL Access Token 的有效期, 没有配置时使用默认值
+func AccessTokenTTL() time.Duration {
+	if config.Auth == nil || config.Auth.AccessTokenTTL <= 0 {
+		return defaultAccessTokenTTL
+	}
+	return config.Auth.AccessTokenTTL
+}
+
+// RefreshTokenTTL Refresh Token 的有效期, 没有配置时使用默认值
+func RefreshTokenTTL() time.Duration {
+	if config.Auth == nil || config.Auth.RefreshTokenTTL <= 0 {
+		return defaultRefreshTokenTTL
+	}
+	return config.Auth.RefreshTokenTTL
+}
+
+// RandomToken 生成 n 字节的随机串(十六进制编码), 用于 Refresh Token、会话ID等
+func RandomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
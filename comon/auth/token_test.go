@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github/lhh-gh/go-mall/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testKeyA = config.JwtKey{Id: "hs-a", Algorithm: "HS256", Secret: "secret-a"}
+	testKeyB = config.JwtKey{Id: "hs-b", Algorithm: "HS256", Secret: "secret-b"}
+)
+
+// useTestKeys 替换配置中的签名密钥并重新加载, 用例结束后还原
+func useTestKeys(t *testing.T, signingKeyId string, jwtKeys ...config.JwtKey) {
+	t.Helper()
+	originId, originKeys := config.Auth.SigningKeyId, config.Auth.Keys
+	config.Auth.SigningKeyId, config.Auth.Keys = signingKeyId, jwtKeys
+	keysOnce, keys, keysErr = sync.Once{}, nil, nil
+	t.Cleanup(func() {
+		config.Auth.SigningKeyId, config.Auth.Keys = originId, originKeys
+		keysOnce, keys, keysErr = sync.Once{}, nil, nil
+	})
+}
+
+func signTestToken(t *testing.T) string {
+	t.Helper()
+	token, _, err := SignAccessToken(1, "session-1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SignAccessToken() error = %v", err)
+	}
+	return token
+}
+
+// writeRSAKeyFiles 生成RSA密钥对并写入PEM文件, 返回私钥文件和公钥文件的路径
+func writeRSAKeyFiles(t *testing.T) (privateKeyFile, publicKeyFile string, publicKeyPem []byte) {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key error: %v", err)
+	}
+	publicKeyDer, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key error: %v", err)
+	}
+	publicKeyPem = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDer})
+	dir := t.TempDir()
+	privateKeyFile, publicKeyFile = filepath.Join(dir, "jwt.key"), filepath.Join(dir, "jwt.pub")
+	privateKeyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	if err = os.WriteFile(privateKeyFile, privateKeyPem, 0600); err != nil {
+		t.Fatalf("write private key error: %v", err)
+	}
+	if err = os.WriteFile(publicKeyFile, publicKeyPem, 0644); err != nil {
+		t.Fatalf("write public key error: %v", err)
+	}
+	return privateKeyFile, publicKeyFile, publicKeyPem
+}
+
+func TestAccessTokenKeyRotation(t *testing.T) {
+	useTestKeys(t, testKeyA.Id, testKeyA)
+	tokenA := signTestToken(t)
+	identity, err := ParseAccessToken(tokenA)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if identity.UserId != 1 || identity.SessionId != "session-1" || identity.TokenId == "" {
+		t.Fatalf("identity = %+v, want user 1 in session-1", identity)
+	}
+
+	// 轮换: 用新密钥签发, 旧密钥保留用于验证轮换前签发的Token
+	useTestKeys(t, testKeyB.Id, testKeyA, testKeyB)
+	tokenB := signTestToken(t)
+	parsed, _, err := jwt.NewParser().ParseUnverified(tokenB, &accessClaims{})
+	if err != nil || parsed.Header["kid"] != testKeyB.Id {
+		t.Fatalf("token signed after rotation has kid %v, want %s", parsed.Header["kid"], testKeyB.Id)
+	}
+	for name, token := range map[string]string{"before rotation": tokenA, "after rotation": tokenB} {
+		if _, err = ParseAccessToken(token); err != nil {
+			t.Errorf("ParseAccessToken(%s) error = %v", name, err)
+		}
+	}
+
+	// 旧密钥下线后, 轮换前签发的Token不能再使用
+	useTestKeys(t, testKeyB.Id, testKeyB)
+	if _, err = ParseAccessToken(tokenA); err == nil {
+		t.Error("token signed with removed key is still valid")
+	}
+	if _, err = ParseAccessToken(tokenB); err != nil {
+		t.Errorf("ParseAccessToken(after rotation) error = %v", err)
+	}
+}
+
+func TestParseAccessTokenRejects(t *testing.T) {
+	privateKeyFile, publicKeyFile, publicKeyPem := writeRSAKeyFiles(t)
+	rsaKey := config.JwtKey{Id: "rs-1", Algorithm: "RS256", PrivateKeyFile: privateKeyFile, PublicKeyFile: publicKeyFile}
+	useTestKeys(t, testKeyA.Id, testKeyA, rsaKey)
+	valid := signTestToken(t)
+
+	signWith := func(method jwt.SigningMethod, kid string, key interface{}, modify func(c *accessClaims)) string {
+		now := time.Now()
+		claims := &accessClaims{
+			SessionId: "session-1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    config.Auth.Issuer,
+				Subject:   "1",
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		if modify != nil {
+			modify(claims)
+		}
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign token error: %v", err)
+		}
+		return signed
+	}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "tampered signature", token: valid[:len(valid)-2] + "xx"},
+		{name: "unknown kid", token: signWith(jwt.SigningMethodHS256, "hs-unknown", []byte(testKeyA.Secret), nil)},
+		{name: "wrong secret", token: signWith(jwt.SigningMethodHS256, testKeyA.Id, []byte("other"), nil)},
+		{
+			// 用RS256公钥作为HS256密钥伪造Token
+			name:  "algorithm confusion",
+			token: signWith(jwt.SigningMethodHS256, rsaKey.Id, publicKeyPem, nil),
+		},
+		{
+			name: "expired",
+			token: signWith(jwt.SigningMethodHS256, testKeyA.Id, []byte(testKeyA.Secret), func(c *accessClaims) {
+				c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			}),
+		},
+		{
+			name: "without expiration",
+			token: signWith(jwt.SigningMethodHS256, testKeyA.Id, []byte(testKeyA.Secret), func(c *accessClaims) {
+				c.ExpiresAt = nil
+			}),
+		},
+		{
+			name: "wrong issuer",
+			token: signWith(jwt.SigningMethodHS256, testKeyA.Id, []byte(testKeyA.Secret), func(c *accessClaims) {
+				c.Issuer = "other"
+			}),
+		},
+		{name: "none algorithm", token: signWith(jwt.SigningMethodNone, testKeyA.Id, jwt.UnsafeAllowNoneSignatureType, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if identity, err := ParseAccessToken(tt.token); err == nil {
+				t.Errorf("ParseAccessToken() = %+v, want error", identity)
+			}
+		})
+	}
+}
+
+func TestAccessTokenRS256(t *testing.T) {
+	privateKeyFile, publicKeyFile, _ := writeRSAKeyFiles(t)
+	rsaKey := config.JwtKey{Id: "rs-1", Algorithm: "RS256", PrivateKeyFile: privateKeyFile, PublicKeyFile: publicKeyFile}
+	useTestKeys(t, rsaKey.Id, rsaKey)
+	token := signTestToken(t)
+
+	// 其他服务实例只配置公钥, 只能验证不能签发
+	verifyOnly := rsaKey
+	verifyOnly.PrivateKeyFile = ""
+	useTestKeys(t, verifyOnly.Id, verifyOnly)
+	if _, err := ParseAccessToken(token); err != nil {
+		t.Errorf("ParseAccessToken() with public key only error = %v", err)
+	}
+	if _, _, err := SignAccessToken(1, "session-1", time.Now().Add(time.Hour)); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("SignAccessToken() with public key only error = %v, want key not available", err)
+	}
+}
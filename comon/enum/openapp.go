@@ -0,0 +1,7 @@
+package enum
+
+// 开放平台应用的状态
+const (
+	OpenAppStatusDisabled = 0
+	OpenAppStatusEnabled  = 1
+)
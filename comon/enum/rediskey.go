@@ -7,3 +7,27 @@ package enum
 const (
 	REDIS_KEY_DEMO_ORDER_DETAIL = "GOMALL:DEMO:ORDER_DETAIL_%s"
 )
+
+// 限流状态的Key前缀, 完整的Key为: 前缀 + 路由组名:限流维度的值
+const REDIS_KEY_RATE_LIMIT_PREFIX = "GOMALL:RATE_LIMIT:"
+
+// 用户认证相关的Key
+const (
+	REDIS_KEY_REFRESH_TOKEN   = "GOMALL:AUTH:REFRESH_TOKEN_%s"   // Refresh Token 的摘要 => 会话信息
+	REDIS_KEY_REVOKED_SESSION = "GOMALL:AUTH:REVOKED_SESSION_%s" // 已吊销的会话, 有效期与 Access Token 相同
+)
+
+// 用户权限缓存的Key
+const REDIS_KEY_USER_PERMISSIONS = "GOMALL:RBAC:USER_PERMISSIONS_%d"
+
+// 幂等请求的Key, 完整格式为: 前缀 + 用户标识:Idempotency-Key
+const REDIS_KEY_IDEMPOTENCY_PREFIX = "GOMALL:IDEMPOTENCY:"
+
+// 开放平台相关的Key
+const (
+	REDIS_KEY_OPEN_APP       = "GOMALL:OPEN_API:APP_%s"      // AppKey => 应用信息
+	REDIS_KEY_OPEN_API_NONCE = "GOMALL:OPEN_API:NONCE_%s_%s" // 已使用过的随机串, 格式为 AppKey_随机串
+)
+
+// 功能开关在Redis中的覆盖配置, Hash结构: 开关名 => 开关配置的JSON
+const REDIS_KEY_FEATURE_FLAGS = "GOMALL:FEATURE_FLAGS"
@@ -16,6 +16,11 @@ var (
 	ErrForbidden       = newError(10000005, "未授权") // 访问一些未授权的资源时的错误
 	ErrTooManyRequests = newError(10000006, "请求过多")
 	ErrCoverData       = newError(10000007, "ConvertDataError") // 数据转换错误
+	ErrTimeout         = newError(10000008, "请求处理超时, 请稍后重试")
+	ErrConflict        = newError(10000009, "请求正在处理中, 请勿重复提交")
+	ErrIdempotencyKey  = newError(10000010, "Idempotency-Key 已被其他请求使用")
+	ErrSignature       = newError(10000011, "请求签名无效")
+	ErrMaintenance     = newError(10000012, "系统维护中, 请稍后再试")
 )
 
 // 用户模块相关错误码 10000100 ~ 1000199
@@ -37,6 +42,18 @@ var (
 	ErrCartWrongUser = newError(10000301, "用户购物信息不匹配")
 )
 
+// 权限模块相关错误码 10000400 ~ 1000499
+var (
+	ErrRoleNotExists       = newError(10000400, "角色不存在")
+	ErrPermissionNotExists = newError(10000401, "权限不存在")
+)
+
+// 开放平台模块相关错误码 10000500 ~ 1000599
+var (
+	ErrOpenAppNotExists = newError(10000500, "应用不存在")
+	ErrOpenAppDisabled  = newError(10000501, "应用已停用")
+)
+
 // 各个业务模块自定义的错误码, 从 10000100 开始, 可以按照不同的业务模块划分不同的号段
 
 //var (
@@ -51,14 +68,20 @@ func (e *AppError) HttpStatusCode() int {
 		return http.StatusInternalServerError
 	case ErrParams.Code():
 		return http.StatusBadRequest
-	case ErrNotFound.Code():
+	case ErrNotFound.Code(), ErrOpenAppNotExists.Code():
 		return http.StatusNotFound
 	case ErrTooManyRequests.Code():
 		return http.StatusTooManyRequests
-	case ErrToken.Code():
+	case ErrToken.Code(), ErrSignature.Code():
 		return http.StatusUnauthorized
-	case ErrForbidden.Code():
+	case ErrForbidden.Code(), ErrOpenAppDisabled.Code():
 		return http.StatusForbidden
+	case ErrTimeout.Code(), ErrMaintenance.Code():
+		return http.StatusServiceUnavailable
+	case ErrConflict.Code():
+		return http.StatusConflict
+	case ErrIdempotencyKey.Code():
+		return http.StatusUnprocessableEntity
 	default:
 		return http.StatusInternalServerError
 	}
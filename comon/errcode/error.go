@@ -8,10 +8,11 @@ import (
 )
 
 type AppError struct {
-	code     int    `json:"code"`
-	msg      string `json:"msg"`
-	cause    error  `json:"cause"`
-	occurred string `json:"occurred"` // 保存由底层错误导致AppErr发生时的位置
+	code     int
+	msg      string
+	cause    error
+	detail   string // 返回给调用方的错误详情, 比如哪个参数不合法
+	occurred string // 保存由底层错误导致AppErr发生时的位置
 }
 
 // 实现error接口  Error方法变成error 类型
@@ -42,6 +43,17 @@ func (e *AppError) String() string {
 	return e.Error()
 }
 
+// Unwrap 返回导致错误的底层错误, 让 errors.Is/errors.As 可以检查错误链条
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// Is 错误码相同的预定义错误视为同一个错误, WithCause、WithDetail 返回的副本也可以用 errors.Is 和预定义错误比较
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && e.code >= 0 && t.code == e.code
+}
+
 func (e *AppError) Code() int {
 	return e.code
 }
@@ -50,14 +62,31 @@ func (e *AppError) Msg() string {
 	return e.msg
 }
 
+func (e *AppError) Detail() string {
+	return e.detail
+}
+
 // WithCause 在逻辑执行中出现错误, 比如dao层返回的数据库查询错误
 // 可以在领域层返回预定义的错误前附加上导致错误的基础错误。
 // 如果业务模块预定义的错误码比较详细, 可以使用这个方法, 反之错误码定义的比较笼统建议使用Wrap方法包装底层错误生成项目自定义Error
 // 并将其记录到日志后再使用预定义错误码返回接口响应
+//
+// 返回的是附带了底层错误的副本, 不会修改预定义的错误, 并发请求之间不会互相覆盖错误链条
 func (e *AppError) WithCause(err error) *AppError {
-	e.cause = err
-	e.occurred = getAppErrOccurredInfo()
-	return e
+	appErr := *e
+	appErr.cause = err
+	appErr.occurred = getAppErrOccurredInfo()
+	return &appErr
+}
+
+// WithDetail 返回一个附带错误详情的副本, 详情会跟随接口响应返回给调用方, 同时作为错误链条记录到日志中。
+// WithCause 附带的底层错误只记录到日志, 参数不合法这类需要告诉调用方原因的错误使用 WithDetail
+func (e *AppError) WithDetail(err error) *AppError {
+	appErr := *e
+	appErr.cause = err
+	appErr.detail = err.Error()
+	appErr.occurred = getAppErrOccurredInfo()
+	return &appErr
 }
 
 // newError 创建新的应用错误实例
@@ -0,0 +1,76 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestWithCauseReturnsCopy(t *testing.T) {
+	cause := errors.New("db down")
+	tests := []struct {
+		name string
+		make func() *AppError
+	}{
+		{name: "WithCause", make: func() *AppError { return ErrServer.WithCause(cause) }},
+		{name: "WithDetail", make: func() *AppError { return ErrServer.WithDetail(cause) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := tt.make()
+			if appErr == ErrServer {
+				t.Fatal("returned the predefined error itself")
+			}
+			if ErrServer.Unwrap() != nil || ErrServer.occurred != "" || ErrServer.Detail() != "" {
+				t.Fatalf("predefined error was modified: %v", ErrServer)
+			}
+			if appErr.Code() != ErrServer.Code() || appErr.Msg() != ErrServer.Msg() {
+				t.Errorf("copy = %d %s, want code and msg of ErrServer", appErr.Code(), appErr.Msg())
+			}
+			if !errors.Is(appErr, cause) {
+				t.Error("errors.Is(copy, cause) = false")
+			}
+			if !errors.Is(appErr, ErrServer) {
+				t.Error("errors.Is(copy, ErrServer) = false")
+			}
+			if errors.Is(appErr, ErrParams) {
+				t.Error("errors.Is(copy, ErrParams) = true")
+			}
+		})
+	}
+}
+
+func TestWithCauseConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cause := fmt.Errorf("cause %d", i)
+			if appErr := ErrServer.WithCause(cause); appErr.Unwrap() != cause {
+				t.Errorf("cause of request %d was overwritten by %v", i, appErr.Unwrap())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestErrorChainWithSameCode(t *testing.T) {
+	// 用预定义错误包装自己时链条也会结束, errors.Is 不会死循环
+	appErr := ErrServer.WithCause(Wrap("query failed", ErrServer.WithCause(errors.New("timeout"))))
+	if !errors.Is(appErr, ErrServer) {
+		t.Error("errors.Is(chain, ErrServer) = false")
+	}
+	if errors.Is(appErr, ErrNotFound) {
+		t.Error("errors.Is(chain, ErrNotFound) = true")
+	}
+	var target *AppError
+	if !errors.As(appErr, &target) || target.Code() != ErrServer.Code() {
+		t.Errorf("errors.As(chain) = %v", target)
+	}
+	// Wrap 生成的错误没有错误码, 不能互相匹配
+	if errors.Is(Wrap("a", errors.New("x")), Wrap("b", errors.New("y"))) {
+		t.Error("errors.Is matched two wrapped errors without error code")
+	}
+}
@@ -0,0 +1,233 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 功能开关: 开关的默认配置来自配置文件 feature_flag, 覆盖配置存储(项目中是 Redis)中的同名开关会覆盖配置文件中的配置
+// 覆盖配置在进程内缓存 refreshInterval, 修改后最多这么久在所有服务实例上生效
+// 读取覆盖配置出错时继续使用上一次读到的覆盖配置; 没有设置覆盖配置存储时只使用配置文件中的开关
+
+// MaintenancePrefix 维护模式开关的前缀, 开关 maintenance:路由组名 开启时路由组进入维护状态
+const MaintenancePrefix = "maintenance:"
+
+const refreshInterval = 5 * time.Second
+
+// Flag 功能开关
+type Flag struct {
+	Enabled    bool    `json:"enabled"`              // 总开关, 关闭时对所有用户都关闭
+	Percentage *int    `json:"percentage,omitempty"` // 按用户ID灰度的比例 0~100, 为空时对所有用户开启
+	UserIds    []int64 `json:"user_ids,omitempty"`   // 不受灰度比例限制, 始终开启的用户
+}
+
+// FlagState 开关的当前状态, 用于管理接口展示
+type FlagState struct {
+	Name       string `json:"name"`
+	Flag       *Flag  `json:"flag"`
+	Overridden bool   `json:"overridden"` // 是否被Redis中的配置覆盖
+}
+
+// OverrideStore 开关覆盖配置的存储, 开关配置以JSON保存, 项目中使用 cache.FeatureFlagStore
+// 开关包不直接依赖 dal, 单元测试和不需要覆盖配置的场景可以不连接Redis
+type OverrideStore interface {
+	GetOverrides(ctx context.Context) (map[string]string, error) // 开关名 => 开关配置的JSON
+	SetOverride(ctx context.Context, name string, flagJson []byte) error
+	DelOverride(ctx context.Context, name string) error
+}
+
+// ErrNoOverrideStore 没有设置覆盖配置存储时不能修改开关
+var ErrNoOverrideStore = errors.New("feature flag override store is not set")
+
+var (
+	store OverrideStore
+
+	overridesMu       sync.RWMutex
+	overrides         = make(map[string]*Flag)
+	overridesLoadedAt time.Time
+	overridesVersion  int // 覆盖配置被修改的次数, 修改前发起的加载结果不再使用
+)
+
+// IsEnabled 判断开关对上下文中的认证用户是否开启, 没有认证用户时只看总开关和灰度比例是否为100
+func IsEnabled(ctx context.Context, name string) bool {
+	return IsEnabledForUser(ctx, name, auth.GetUserId(ctx))
+}
+
+// IsEnabledForUser 判断开关对指定用户是否开启, 不存在的开关视为关闭
+func IsEnabledForUser(ctx context.Context, name string, userId int64) bool {
+	name = strings.ToLower(name)
+	flag := getFlag(ctx, name)
+	if flag == nil || !flag.Enabled {
+		return false
+	}
+	if userId > 0 {
+		for _, targetId := range flag.UserIds {
+			if targetId == userId {
+				return true
+			}
+		}
+	}
+	if flag.Percentage == nil {
+		return len(flag.UserIds) == 0
+	}
+	if *flag.Percentage >= 100 {
+		return true
+	}
+	if userId <= 0 || *flag.Percentage <= 0 {
+		return false
+	}
+	return bucket(name, userId) < *flag.Percentage
+}
+
+// SetOverrideStore 设置开关覆盖配置的存储, 在 main 中连接Redis后、注册路由前调用
+func SetOverrideStore(overrideStore OverrideStore) {
+	overridesMu.Lock()
+	store = overrideStore
+	overrides = make(map[string]*Flag)
+	overridesLoadedAt = time.Time{}
+	overridesVersion++
+	overridesMu.Unlock()
+}
+
+// SetOverride 在覆盖配置存储中覆盖开关的配置
+func SetOverride(ctx context.Context, name string, flag *Flag) error {
+	overrideStore := getStore()
+	if overrideStore == nil {
+		return ErrNoOverrideStore
+	}
+	flagJson, _ := json.Marshal(flag)
+	if err := overrideStore.SetOverride(ctx, strings.ToLower(name), flagJson); err != nil {
+		return err
+	}
+	expireOverrides()
+	return nil
+}
+
+// DelOverride 删除开关在覆盖配置存储中的配置, 恢复使用配置文件中的配置
+func DelOverride(ctx context.Context, name string) error {
+	overrideStore := getStore()
+	if overrideStore == nil {
+		return ErrNoOverrideStore
+	}
+	if err := overrideStore.DelOverride(ctx, strings.ToLower(name)); err != nil {
+		return err
+	}
+	expireOverrides()
+	return nil
+}
+
+// List 列出所有开关的当前状态
+func List(ctx context.Context) []*FlagState {
+	loadOverrides(ctx)
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	states := make([]*FlagState, 0, len(config.FeatureFlags)+len(overrides))
+	for name, flag := range overrides {
+		states = append(states, &FlagState{Name: name, Flag: flag, Overridden: true})
+	}
+	for name, flagConf := range config.FeatureFlags {
+		if _, ok := overrides[name]; ok {
+			continue
+		}
+		states = append(states, &FlagState{Name: name, Flag: fromConfig(flagConf)})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+func getFlag(ctx context.Context, name string) *Flag {
+	loadOverrides(ctx)
+	overridesMu.RLock()
+	flag, ok := overrides[name]
+	overridesMu.RUnlock()
+	if ok {
+		return flag
+	}
+	if flagConf, ok := config.FeatureFlags[name]; ok {
+		return fromConfig(flagConf)
+	}
+	return nil
+}
+
+// loadOverrides 覆盖配置过期后从覆盖配置存储重新加载
+// 只有发现过期的那个请求去读存储, 读存储时不持有锁, 其他请求继续使用上一次读到的覆盖配置
+func loadOverrides(ctx context.Context) {
+	overridesMu.RLock()
+	fresh := store == nil || time.Since(overridesLoadedAt) < refreshInterval
+	overridesMu.RUnlock()
+	if fresh {
+		return
+	}
+
+	overridesMu.Lock()
+	if store == nil || time.Since(overridesLoadedAt) < refreshInterval {
+		overridesMu.Unlock()
+		return
+	}
+	// 无论成功与否都推迟下一次加载, 避免Redis故障时每次判断开关都去访问Redis
+	overridesLoadedAt = time.Now()
+	version := overridesVersion
+	overrideStore := store
+	overridesMu.Unlock()
+
+	values, err := overrideStore.GetOverrides(ctx)
+	if err != nil {
+		return
+	}
+	loaded := make(map[string]*Flag, len(values))
+	for name, value := range values {
+		flag := new(Flag)
+		if err = json.Unmarshal([]byte(value), flag); err != nil {
+			logger.New(ctx).Error("feature flag override decode error", "name", name, "value", value, "err", err)
+			continue
+		}
+		loaded[name] = flag
+	}
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	if version == overridesVersion {
+		overrides = loaded
+	}
+}
+
+func getStore() OverrideStore {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	return store
+}
+
+func expireOverrides() {
+	overridesMu.Lock()
+	overridesLoadedAt = time.Time{}
+	overridesVersion++
+	overridesMu.Unlock()
+}
+
+func fromConfig(flagConf config.FeatureFlag) *Flag {
+	return &Flag{
+		Enabled:    flagConf.Enabled,
+		Percentage: flagConf.Percentage,
+		UserIds:    flagConf.UserIds,
+	}
+}
+
+// bucket 把用户稳定地分到 0~99 的桶中, 同一个用户在不同开关中的分桶相互独立
+func bucket(name string, userId int64) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write([]byte(strconv.FormatInt(userId, 10)))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,196 @@
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"github/lhh-gh/go-mall/config"
+	"sync"
+	"testing"
+)
+
+// memoryStore 测试用的覆盖配置存储
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string]string
+	err    error // 不为空时读取覆盖配置返回这个错误
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: make(map[string]string)}
+}
+
+func (s *memoryStore) GetOverrides(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	values := make(map[string]string, len(s.values))
+	for name, value := range s.values {
+		values[name] = value
+	}
+	return values, nil
+}
+
+func (s *memoryStore) SetOverride(ctx context.Context, name string, flagJson []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[name] = string(flagJson)
+	return nil
+}
+
+func (s *memoryStore) DelOverride(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, name)
+	return nil
+}
+
+// useTestStore 设置覆盖配置存储, 用例结束后清除
+func useTestStore(t *testing.T, overrideStore OverrideStore) {
+	SetOverrideStore(overrideStore)
+	t.Cleanup(func() { SetOverrideStore(nil) })
+}
+
+func TestBucket(t *testing.T) {
+	// 同一个用户在同一个开关中的分桶是稳定的
+	for userId := int64(1); userId <= 1000; userId++ {
+		b := bucket("new_checkout", userId)
+		if b < 0 || b >= 100 {
+			t.Fatalf("bucket(new_checkout, %d) = %d, want 0~99", userId, b)
+		}
+		if again := bucket("new_checkout", userId); again != b {
+			t.Fatalf("bucket(new_checkout, %d) = %d then %d, want stable", userId, b, again)
+		}
+	}
+
+	// 用户在各个桶中大致均匀分布, 灰度比例和实际命中的用户比例接近
+	const users = 10000
+	hits := 0
+	for userId := int64(1); userId <= users; userId++ {
+		if bucket("new_checkout", userId) < 30 {
+			hits++
+		}
+	}
+	if ratio := float64(hits) / users; ratio < 0.27 || ratio > 0.33 {
+		t.Errorf("30%% rollout hit %.2f of users, want about 0.30", ratio)
+	}
+
+	// 不同开关的分桶相互独立, 不会总是同一批用户先进入灰度
+	same := 0
+	for userId := int64(1); userId <= users; userId++ {
+		if (bucket("new_checkout", userId) < 30) == (bucket("new_search", userId) < 30) {
+			same++
+		}
+	}
+	// 两个开关独立时 30% 灰度的结果相同的比例约为 0.3*0.3+0.7*0.7=0.58
+	if ratio := float64(same) / users; ratio > 0.65 {
+		t.Errorf("rollouts of different flags agree on %.2f of users, want independent buckets", ratio)
+	}
+}
+
+func TestIsEnabledForUser(t *testing.T) {
+	percentage := func(p int) *int { return &p }
+	// 找一个在 50% 灰度内和一个在灰度外的用户
+	var inUser, outUser int64
+	for userId := int64(1); inUser == 0 || outUser == 0; userId++ {
+		if bucket("half", userId) < 50 {
+			inUser = userId
+		} else {
+			outUser = userId
+		}
+	}
+
+	originFlags := config.FeatureFlags
+	config.FeatureFlags = map[string]config.FeatureFlag{
+		"off":      {Enabled: false, UserIds: []int64{inUser}},
+		"on":       {Enabled: true},
+		"targeted": {Enabled: true, UserIds: []int64{inUser}},
+		"zero":     {Enabled: true, Percentage: percentage(0), UserIds: []int64{inUser}},
+		"half":     {Enabled: true, Percentage: percentage(50)},
+		"all":      {Enabled: true, Percentage: percentage(100)},
+	}
+	t.Cleanup(func() { config.FeatureFlags = originFlags })
+	// 没有覆盖配置存储, 只使用配置文件中的开关
+	useTestStore(t, nil)
+
+	tests := []struct {
+		name   string
+		flag   string
+		userId int64
+		want   bool
+	}{
+		{name: "unknown flag", flag: "missing", userId: inUser, want: false},
+		{name: "disabled flag ignores target users", flag: "off", userId: inUser, want: false},
+		{name: "enabled for everyone", flag: "on", userId: outUser, want: true},
+		{name: "enabled for anonymous", flag: "on", userId: 0, want: true},
+		{name: "target user", flag: "targeted", userId: inUser, want: true},
+		{name: "not a target user", flag: "targeted", userId: outUser, want: false},
+		{name: "target user bypasses zero percentage", flag: "zero", userId: inUser, want: true},
+		{name: "zero percentage", flag: "zero", userId: outUser, want: false},
+		{name: "user inside rollout", flag: "half", userId: inUser, want: true},
+		{name: "user outside rollout", flag: "half", userId: outUser, want: false},
+		{name: "anonymous in partial rollout", flag: "half", userId: 0, want: false},
+		{name: "full rollout for anonymous", flag: "all", userId: 0, want: true},
+		{name: "flag name is case insensitive", flag: "ON", userId: outUser, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsEnabledForUser(context.Background(), tt.flag, tt.userId); got != tt.want {
+				t.Errorf("IsEnabledForUser(%s, %d) = %v, want %v", tt.flag, tt.userId, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	ctx := context.Background()
+	originFlags := config.FeatureFlags
+	config.FeatureFlags = map[string]config.FeatureFlag{"new_checkout": {Enabled: false}}
+	t.Cleanup(func() { config.FeatureFlags = originFlags })
+	overrideStore := newMemoryStore()
+	useTestStore(t, overrideStore)
+
+	if IsEnabledForUser(ctx, "new_checkout", 1) {
+		t.Fatal("flag enabled before override")
+	}
+	// 修改覆盖配置后当前实例立即生效, 不用等 refreshInterval
+	if err := SetOverride(ctx, "New_Checkout", &Flag{Enabled: true}); err != nil {
+		t.Fatalf("SetOverride() error = %v", err)
+	}
+	if !IsEnabledForUser(ctx, "new_checkout", 1) {
+		t.Error("flag disabled after override")
+	}
+	states := List(ctx)
+	if len(states) != 1 || states[0].Name != "new_checkout" || !states[0].Overridden || !states[0].Flag.Enabled {
+		t.Errorf("List() = %+v, want overridden new_checkout", states)
+	}
+
+	// 读取覆盖配置出错时继续使用上一次读到的配置
+	overrideStore.err = errors.New("redis down")
+	expireOverrides()
+	if !IsEnabledForUser(ctx, "new_checkout", 1) {
+		t.Error("override lost when store fails")
+	}
+	overrideStore.err = nil
+
+	if err := DelOverride(ctx, "new_checkout"); err != nil {
+		t.Fatalf("DelOverride() error = %v", err)
+	}
+	if IsEnabledForUser(ctx, "new_checkout", 1) {
+		t.Error("flag still enabled after override deleted")
+	}
+	if states = List(ctx); len(states) != 1 || states[0].Overridden {
+		t.Errorf("List() = %+v, want new_checkout from config", states)
+	}
+}
+
+func TestOverridesWithoutStore(t *testing.T) {
+	useTestStore(t, nil)
+	if err := SetOverride(context.Background(), "new_checkout", &Flag{Enabled: true}); !errors.Is(err, ErrNoOverrideStore) {
+		t.Errorf("SetOverride() error = %v, want ErrNoOverrideStore", err)
+	}
+	if err := DelOverride(context.Background(), "new_checkout"); !errors.Is(err, ErrNoOverrideStore) {
+		t.Errorf("DelOverride() error = %v, want ErrNoOverrideStore", err)
+	}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterDBStats 注册数据库连接池的指标, name 用于区分主库和从库
+func RegisterDBStats(name string, db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRedisPoolStats 注册 Redis 连接池的指标
+func RegisterRedisPoolStats(client *redis.Client) {
+	Registry.MustRegister(&redisPoolCollector{client: client})
+}
+
+var (
+	redisPoolHits = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "hits_total"),
+		"Number of times a free connection was found in the pool.", nil, nil)
+	redisPoolMisses = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "misses_total"),
+		"Number of times a free connection was NOT found in the pool.", nil, nil)
+	redisPoolTimeouts = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "timeouts_total"),
+		"Number of times a wait timeout occurred.", nil, nil)
+	redisPoolTotalConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "total_connections"),
+		"Number of total connections in the pool.", nil, nil)
+	redisPoolIdleConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "idle_connections"),
+		"Number of idle connections in the pool.", nil, nil)
+	redisPoolStaleConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", "stale_connections_total"),
+		"Number of stale connections removed from the pool.", nil, nil)
+)
+
+// redisPoolCollector 采集时读取 Redis 客户端的 PoolStats
+type redisPoolCollector struct {
+	client *redis.Client
+}
+
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisPoolHits
+	ch <- redisPoolMisses
+	ch <- redisPoolTimeouts
+	ch <- redisPoolTotalConns
+	ch <- redisPoolIdleConns
+	ch <- redisPoolStaleConns
+}
+
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisPoolHits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(redisPoolMisses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(redisPoolTimeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisPoolTotalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisPoolIdleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(redisPoolStaleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+// 项目的 Prometheus 指标, 通过 /metrics 接口暴露
+// 指标统一使用 gomall_ 前缀, 标签的取值要保证是有限集合(比如用路由模板而不是请求路径), 避免指标数量膨胀
+
+const namespace = "gomall"
+
+// Registry 项目使用独立的 Registry, 不混入第三方库注册到默认 Registry 的指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestsTotal 接口请求数
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration 接口耗时
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// SQLQueryDuration SQL执行耗时
+	SQLQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sql",
+		Name:      "query_duration_seconds",
+		Help:      "GORM query latency by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	// SQLQueryErrors SQL执行出错次数, 查询不到记录不算错误
+	SQLQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sql",
+		Name:      "query_errors_total",
+		Help:      "Total number of failed GORM queries by operation and table.",
+	}, []string{"operation", "table"})
+
+	// RedisCommandDuration Redis命令耗时
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency by command and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+
+	// HTTPClientRequestDuration 调用外部HTTP接口的耗时
+	HTTPClientRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http_client",
+		Name:      "request_duration_seconds",
+		Help:      "Outbound HTTP request latency by host, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "method", "status"})
+
+	// DBReplicaUp 数据库从库的健康状态: 1-健康 0-健康检查失败, 已摘除
+	DBReplicaUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "replica_up",
+		Help:      "Whether the database replica passed the last health check (1 up, 0 ejected).",
+	}, []string{"replica"})
+
+	// HTTPClientCircuitState 调用外部HTTP接口的熔断器状态: 0-关闭 1-半开 2-打开
+	HTTPClientCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http_client",
+		Name:      "circuit_state",
+		Help:      "Circuit breaker state by host (0 closed, 1 half-open, 2 open).",
+	}, []string{"host"})
+
+	// HTTPClientCircuitRejections 被熔断器直接拒绝的请求数
+	HTTPClientCircuitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http_client",
+		Name:      "circuit_rejections_total",
+		Help:      "Total number of outbound requests rejected by an open circuit breaker.",
+	}, []string{"host"})
+
+	// BusinessErrorsTotal 通过统一响应返回的业务错误码
+	BusinessErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "app",
+		Name:      "business_errors_total",
+		Help:      "Total number of error codes returned through the unified response.",
+	}, []string{"code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		SQLQueryDuration,
+		SQLQueryErrors,
+		RedisCommandDuration,
+		HTTPClientRequestDuration,
+		HTTPClientCircuitState,
+		DBReplicaUp,
+		HTTPClientCircuitRejections,
+		BusinessErrorsTotal,
+	)
+}
+
+// Handler 暴露指标的 HTTP Handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
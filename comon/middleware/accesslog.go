@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 访问日志, 按路由从配置 access_log 中读取选项:
+//   - 是否记录请求体和响应体, 以及最多记录的字节数, 超出部分截断
+//   - 指定类型(比如文件上传下载、SSE)的请求体和响应体不记录
+//   - 需要记录的请求头
+//   - 是否在请求开始时记录 access_start 日志
+// 请求体只预读最多 max_body_size 个字节, 响应体边输出边记录, 大文件和流式响应不会整体堆积在内存中
+
+const (
+	defaultAccessLogMaxBodySize = 10 * 1024
+	accessLogTruncatedMark      = "...(truncated)"
+)
+
+var defaultAccessLogSkipContentTypes = []string{"multipart/form-data", "application/octet-stream", "text/event-stream"}
+
+// accessLogOption 路由最终使用的访问日志选项
+type accessLogOption struct {
+	captureBody      bool
+	maxBodySize      int
+	skipContentTypes []string
+	headers          []string
+	logStart         bool
+}
+
+// LogAccess 访问日志中间件
+// 记录请求的详细信息，包括请求头、请求体、响应体、处理时间等
+func LogAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		opt := getAccessLogOption(c.FullPath())
+		var (
+			reqBody  string
+			recorder *accessLogWriter
+		)
+		if opt.captureBody {
+			if !opt.skipContentType(c.GetHeader("Content-Type")) {
+				reqBody = peekRequestBody(c.Request, opt.maxBodySize)
+			}
+			recorder = &accessLogWriter{ResponseWriter: c.Writer, body: new(bytes.Buffer), opt: opt}
+			c.Writer = recorder
+		}
+
+		if opt.logStart {
+			accessLog(c, "access_start", opt, time.Since(start), reqBody, nil)
+		}
+		defer func() {
+			var dataOut interface{}
+			if recorder != nil {
+				dataOut = recorder.String()
+			}
+			accessLog(c, "access_end", opt, time.Since(start), reqBody, dataOut)
+		}()
+		c.Next()
+	}
+}
+
+// accessLog 记录访问日志
+// 参数说明：
+// - c: gin上下文
+// - accessType: 访问类型（开始/结束）
+// - opt: 访问日志选项
+// - dur: 处理时长
+// - body: 请求体
+// - dataOut: 响应数据
+func accessLog(c *gin.Context, accessType string, opt *accessLogOption, dur time.Duration, body string, dataOut interface{}) {
+	req := c.Request
+	// 只记录Token的指纹, 不把Token明文写到日志里
+	token := auth.TokenFingerprint(auth.BearerToken(c))
+	kv := []interface{}{
+		"type", accessType,
+		"ip", c.ClientIP(),
+		"token", token,
+		"method", req.Method,
+		"path", req.URL.Path,
+		"query", req.URL.RawQuery,
+		"body", body,
+		"output", dataOut,
+		"time(ms)", int64(dur / time.Millisecond),
+	}
+	if len(opt.headers) > 0 {
+		headers := make(map[string]string, len(opt.headers))
+		for _, name := range opt.headers {
+			if value := req.Header.Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		kv = append(kv, "headers", headers)
+	}
+	if accessType == "access_end" {
+		kv = append(kv, "status", c.Writer.Status(), "country", ipCountry(c))
+	}
+	logger.New(c).Info("AccessLog", kv...)
+}
+
+// getAccessLogOption 获取路由的访问日志选项, 路由中没有设置的项使用默认值
+func getAccessLogOption(fullPath string) *accessLogOption {
+	opt := &accessLogOption{
+		captureBody:      true,
+		maxBodySize:      defaultAccessLogMaxBodySize,
+		skipContentTypes: defaultAccessLogSkipContentTypes,
+		logStart:         true,
+	}
+	if config.AccessLog == nil {
+		return opt
+	}
+	opt.merge(config.AccessLog.Default)
+	if routeOption, ok := config.AccessLog.Routes[strings.ToLower(fullPath)]; ok {
+		opt.merge(routeOption)
+	}
+	return opt
+}
+
+func (opt *accessLogOption) merge(option config.AccessLogOption) {
+	if option.CaptureBody != nil {
+		opt.captureBody = *option.CaptureBody
+	}
+	if option.MaxBodySize > 0 {
+		opt.maxBodySize = option.MaxBodySize
+	}
+	if option.SkipContentTypes != nil {
+		opt.skipContentTypes = option.SkipContentTypes
+	}
+	if option.Headers != nil {
+		opt.headers = option.Headers
+	}
+	if option.LogStart != nil {
+		opt.logStart = *option.LogStart
+	}
+}
+
+// skipContentType 判断该类型的内容是否不记录, 流式响应始终不记录
+func (opt *accessLogOption) skipContentType(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return true
+	}
+	for _, skip := range opt.skipContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(skip)) {
+			return true
+		}
+	}
+	return false
+}
+
+// peekRequestBody 预读请求体的前 maxSize 个字节用于记录日志, 预读的内容会放回请求体, 不影响后续读取
+func peekRequestBody(req *http.Request, maxSize int) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	prefix, _ := io.ReadAll(io.LimitReader(req.Body, int64(maxSize)+1))
+	req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(prefix), req.Body), Closer: req.Body}
+	if len(prefix) > maxSize {
+		return string(prefix[:maxSize]) + accessLogTruncatedMark
+	}
+	return string(prefix)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// accessLogWriter 包装 gin.ResponseWriter, 在写出响应的同时记录响应的前 maxBodySize 个字节
+type accessLogWriter struct {
+	gin.ResponseWriter
+	body      *bytes.Buffer
+	opt       *accessLogOption
+	truncated bool
+	skipped   bool
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *accessLogWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *accessLogWriter) capture(b []byte) {
+	if w.skipped || w.truncated {
+		return
+	}
+	if w.opt.skipContentType(w.Header().Get("Content-Type")) {
+		w.skipped = true
+		return
+	}
+	remaining := w.opt.maxBodySize - w.body.Len()
+	if len(b) > remaining {
+		b = b[:remaining]
+		w.truncated = true
+	}
+	w.body.Write(b)
+}
+
+func (w *accessLogWriter) String() string {
+	if w.skipped {
+		return ""
+	}
+	if w.truncated {
+		return w.body.String() + accessLogTruncatedMark
+	}
+	return w.body.String()
+}
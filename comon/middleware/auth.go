@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/dal/cache"
+)
+
+// AuthUser 用户认证中间件, 验证请求头 Authorization: Bearer <token> 中的 Access Token
+// 认证通过后把用户身份放到上下文中, 业务代码通过 auth.GetIdentity / auth.GetUserId 读取
+func AuthUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := auth.BearerToken(c)
+		if token == "" {
+			app.NewResponse(c).Error(errcode.ErrToken)
+			c.Abort()
+			return
+		}
+		identity, err := auth.ParseAccessToken(token)
+		if err != nil {
+			logger.New(c).Warn("access_token_invalid", "token", auth.TokenFingerprint(token), "err", err)
+			app.NewResponse(c).Error(errcode.ErrToken)
+			c.Abort()
+			return
+		}
+		revoked, err := cache.IsSessionRevoked(c, identity.SessionId)
+		if err != nil {
+			// 查询不到吊销状态时放行, Access Token 有效期较短, 不能因为缓存故障让所有用户都无法访问
+			logger.New(c).Error("check_session_revoked_error", "session", identity.SessionId, "err", err)
+		}
+		if revoked {
+			app.NewResponse(c).Error(errcode.ErrToken)
+			c.Abort()
+			return
+		}
+		auth.SetIdentity(c, identity)
+		c.Next()
+	}
+}
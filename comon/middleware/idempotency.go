@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/dal/cache"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength   = 128
+	// 没有配置 max_body_size 时计算请求指纹最多读取的请求体大小
+	defaultIdempotencyMaxBodySize = 1 << 20
+)
+
+// Idempotency 幂等请求中间件, 用于创建订单这类不能重复执行的POST接口, 需要放在 AuthUser 之后
+// 客户端在请求头 Idempotency-Key 中携带唯一标识, 同一个用户使用同一个Key重复请求时:
+//   - 第一次请求仍在处理中, 返回 409
+//   - 第一次请求已经完成, 直接返回第一次请求的响应
+//   - Key被用于不同的请求(请求指纹不同), 返回 422
+//
+// 处理函数执行完成后, 不管结果是成功、5xx还是请求已经超时, 都保存处理函数写出的响应: 处理函数可能已经提交了事务,
+// 删除记录会让客户端的重试再执行一次。超时的请求客户端收到的是超时响应, 用同一个Key重试时拿到处理函数真正的响应。
+// 只有处理函数panic时删除记录, 允许客户端用同一个Key重试; 进程崩溃时处理中的记录在 processing_ttl 后过期。
+// 没有携带 Idempotency-Key 的请求不做处理
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(idempotencyKeyHeader)
+		if idempotencyKey == "" || config.Idempotency == nil {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > idempotencyKeyMaxLength {
+			app.NewResponse(c).Error(errcode.ErrParams)
+			c.Abort()
+			return
+		}
+		key := idempotencyOwner(c) + ":" + idempotencyKey
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			app.NewResponse(c).Error(errcode.ErrParams.WithDetail(err))
+			c.Abort()
+			return
+		}
+
+		record := &cache.IdempotencyRecord{Status: cache.IdempotencyProcessing, Fingerprint: fingerprint}
+		acquired, err := cache.AcquireIdempotencyKey(c, key, record, config.Idempotency.ProcessingTTL)
+		if err != nil {
+			// 缓存不可用时放行请求
+			logger.New(c).Error("idempotency_acquire_error", "key", key, "err", err)
+			c.Next()
+			return
+		}
+		if !acquired {
+			replayIdempotentResponse(c, key, fingerprint)
+			return
+		}
+
+		writer := &responseCaptureWriter{ResponseWriter: c.Writer, body: new(bytes.Buffer)}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			c.Writer = writer.ResponseWriter
+			// 请求可能已经超时, 保存结果时不使用请求的 Context
+			ctx := context.WithoutCancel(c)
+			// 只有处理函数panic时删除记录, 是否完成以处理函数是否返回为准, 不看请求的 Context:
+			// 客户端断开或者请求超时后处理函数可能仍然完成了处理, 这时同样要保存结果
+			if !completed {
+				cache.DelIdempotencyRecord(ctx, key)
+				return
+			}
+			record.Status = cache.IdempotencyCompleted
+			record.HttpStatus = writer.statusCode()
+			record.ContentType = writer.Header().Get("Content-Type")
+			record.Body = writer.body.Bytes()
+			cache.SetIdempotencyRecord(ctx, key, record, idempotencyTTL(c.FullPath()))
+		}()
+		c.Next()
+		completed = true
+	}
+}
+
+// replayIdempotentResponse 处理重复请求
+func replayIdempotentResponse(c *gin.Context, key, fingerprint string) {
+	record, err := cache.GetIdempotencyRecord(c, key)
+	if err != nil {
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+		c.Abort()
+		return
+	}
+	switch {
+	case record == nil || record.Status == cache.IdempotencyProcessing:
+		// 记录不存在说明第一次请求刚好处理失败, 也让客户端稍后重试
+		app.NewResponse(c).Error(errcode.ErrConflict)
+	case record.Fingerprint != fingerprint:
+		app.NewResponse(c).Error(errcode.ErrIdempotencyKey)
+	default:
+		c.Header(idempotencyReplayedHeader, "true")
+		c.Data(record.HttpStatus, record.ContentType, record.Body)
+	}
+	c.Abort()
+}
+
+// idempotencyOwner 幂等Key的归属, 已认证的请求按用户区分, 否则按IP区分
+func idempotencyOwner(c *gin.Context) string {
+	if userId := auth.GetUserId(c); userId != 0 {
+		return strconv.FormatInt(userId, 10)
+	}
+	return "ip_" + c.ClientIP()
+}
+
+// requestFingerprint 请求的指纹, 由请求方法、路径、查询参数和请求体计算得到, 请求体超过 max_body_size 时返回错误
+func requestFingerprint(c *gin.Context) (string, error) {
+	maxBodySize := config.Idempotency.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultIdempotencyMaxBodySize
+	}
+	reqBody, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+	h := sha256.New()
+	io.WriteString(h, strings.Join([]string{c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery}, "\n"))
+	h.Write(reqBody)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func idempotencyTTL(fullPath string) time.Duration {
+	if ttl, ok := config.Idempotency.Routes[strings.ToLower(fullPath)]; ok {
+		return ttl
+	}
+	return config.Idempotency.TTL
+}
+
+// responseCaptureWriter 记录写出的响应内容和状态码
+// 状态码要自己记录, 外层的超时中间件在超时后会丢弃 WriteHeader, 这时从 Status() 只能拿到默认的200
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	body    *bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *responseCaptureWriter) WriteHeader(code int) {
+	if !w.written {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseCaptureWriter) Write(b []byte) (int, error) {
+	w.written = true
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.written = true
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// statusCode 处理函数设置的状态码, 没有设置时是200
+func (w *responseCaptureWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
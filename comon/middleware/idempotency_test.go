@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/dal/cache"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newIdempotencyEngine 注册一个使用幂等中间件的路由, handler 返回被调用的次数
+func newIdempotencyEngine(handler func(c *gin.Context)) (*gin.Engine, *int32) {
+	calls := new(int32)
+	engine := gin.New()
+	engine.Use(gin.RecoveryWithWriter(io.Discard))
+	engine.POST("/orders", Idempotency(), func(c *gin.Context) {
+		atomic.AddInt32(calls, 1)
+		handler(c)
+	})
+	return engine, calls
+}
+
+func doIdempotentRequest(engine *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+// newIdempotencyKey 每个用例使用不同的Key, 避免用例之间互相影响
+func newIdempotencyKey(t *testing.T) string {
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(c *gin.Context)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "success is replayed",
+			handler:    func(c *gin.Context) { c.String(http.StatusCreated, "order-1") },
+			wantStatus: http.StatusCreated,
+			wantBody:   "order-1",
+		},
+		{
+			name:       "client error is replayed",
+			handler:    func(c *gin.Context) { c.String(http.StatusBadRequest, "bad") },
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "bad",
+		},
+		{
+			// 处理函数返回5xx前可能已经提交了事务, 重试不能再执行一次
+			name:       "server error is replayed",
+			handler:    func(c *gin.Context) { c.String(http.StatusInternalServerError, "failed") },
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "failed",
+		},
+		{
+			name:       "status without body is replayed",
+			handler:    func(c *gin.Context) { c.Status(http.StatusAccepted) },
+			wantStatus: http.StatusAccepted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, calls := newIdempotencyEngine(tt.handler)
+			key := newIdempotencyKey(t)
+			first := doIdempotentRequest(engine, key, `{"goods_id":1}`)
+			if first.Code != tt.wantStatus || first.Body.String() != tt.wantBody {
+				t.Fatalf("first response = %d %q, want %d %q", first.Code, first.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			second := doIdempotentRequest(engine, key, `{"goods_id":1}`)
+			if second.Code != tt.wantStatus || second.Body.String() != tt.wantBody {
+				t.Errorf("replayed response = %d %q, want %d %q", second.Code, second.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if second.Header().Get(idempotencyReplayedHeader) != "true" {
+				t.Errorf("replayed response missing %s header", idempotencyReplayedHeader)
+			}
+			if got := atomic.LoadInt32(calls); got != 1 {
+				t.Errorf("handler called %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestIdempotencyRejectsMismatchedRequests(t *testing.T) {
+	engine, calls := newIdempotencyEngine(func(c *gin.Context) { c.String(http.StatusCreated, "order-1") })
+	key := newIdempotencyKey(t)
+	doIdempotentRequest(engine, key, `{"goods_id":1}`)
+
+	tests := []struct {
+		name       string
+		key        string
+		body       string
+		wantStatus int
+	}{
+		{name: "same key with different body", key: key, body: `{"goods_id":2}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "key too long", key: strings.Repeat("k", idempotencyKeyMaxLength+1), body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "body too large", key: newIdempotencyKey(t), body: strings.Repeat("x", defaultIdempotencyMaxBodySize+1), wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := doIdempotentRequest(engine, tt.key, tt.body); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+}
+
+func TestIdempotencyConflictWhileProcessing(t *testing.T) {
+	engine, calls := newIdempotencyEngine(func(c *gin.Context) { c.String(http.StatusCreated, "order-1") })
+	key := newIdempotencyKey(t)
+	// 模拟第一次请求还在处理中
+	record := &cache.IdempotencyRecord{Status: cache.IdempotencyProcessing}
+	if _, err := cache.AcquireIdempotencyKey(context.Background(), "ip_192.0.2.1:"+key, record, time.Minute); err != nil {
+		t.Fatalf("acquire key error: %v", err)
+	}
+	if w := doIdempotentRequest(engine, key, `{}`); w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if got := atomic.LoadInt32(calls); got != 0 {
+		t.Errorf("handler called %d times, want 0", got)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	var panicked int32
+	engine, calls := newIdempotencyEngine(func(c *gin.Context) {
+		if atomic.CompareAndSwapInt32(&panicked, 0, 1) {
+			panic("boom")
+		}
+		c.String(http.StatusCreated, "order-1")
+	})
+	key := newIdempotencyKey(t)
+	if w := doIdempotentRequest(engine, key, `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	w := doIdempotentRequest(engine, key, `{}`)
+	if w.Code != http.StatusCreated || w.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("retry after panic = %d replayed=%q, want a fresh 201", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("handler called %d times, want 2", got)
+	}
+}
+
+func TestIdempotencyKeepsResultWhenRequestContextEnds(t *testing.T) {
+	// 处理函数完成了处理, 但请求已经超时或者客户端断开了连接, 重试时要返回处理函数的结果而不是再执行一次
+	calls := new(int32)
+	engine := gin.New()
+	engine.POST("/orders", Idempotency(), func(c *gin.Context) {
+		atomic.AddInt32(calls, 1)
+		c.String(http.StatusCreated, "order-1")
+		// 模拟处理函数写完响应后请求才超时
+		cancelCtx, cancel := context.WithCancel(c.Request.Context())
+		cancel()
+		c.Request = c.Request.WithContext(cancelCtx)
+	})
+	key := newIdempotencyKey(t)
+	doIdempotentRequest(engine, key, `{}`)
+	w := doIdempotentRequest(engine, key, `{}`)
+	if w.Code != http.StatusCreated || w.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Errorf("retry = %d replayed=%q, want replayed 201", w.Code, w.Header().Get(idempotencyReplayedHeader))
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	engine, calls := newIdempotencyEngine(func(c *gin.Context) { c.String(http.StatusCreated, "order") })
+	doIdempotentRequest(engine, "", `{}`)
+	doIdempotentRequest(engine, "", `{}`)
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("handler called %d times, want 2", got)
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/library"
+	"net"
+	"strings"
+)
+
+// 放在上下文中的IP所属国家, 访问日志会读取
+const ipCountryCtxKey = "ip_country"
+
+// IpAccess IP访问控制中间件, 使用配置 ip_access 中 name 对应的规则, 一般一个路由组对应一条规则
+// 判断顺序:
+//  1. IP在 deny_cidrs 中时拒绝
+//  2. 配置了 allow_cidrs 时, IP在其中则放行, 否则拒绝
+//  3. 按IP所属国家匹配 deny_countries 和 allow_countries
+//
+// 查不到IP所属国家(比如内网IP)或者查询失败时, 默认不做国家的限制, 规则中配置 country_fail_closed 时拒绝
+func IpAccess(name string) gin.HandlerFunc {
+	ruleConf, ok := config.IpAccess[name]
+	if !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	allowNets := mustParseCidrs(ruleConf.AllowCidrs)
+	denyNets := mustParseCidrs(ruleConf.DenyCidrs)
+	allowCountries := countrySet(ruleConf.AllowCountries)
+	denyCountries := countrySet(ruleConf.DenyCountries)
+
+	return func(c *gin.Context) {
+		clientIp := c.ClientIP()
+		ip := net.ParseIP(clientIp)
+		if ip == nil {
+			rejectIp(c, name, clientIp, "invalid ip")
+			return
+		}
+		if containsIp(denyNets, ip) {
+			rejectIp(c, name, clientIp, "ip denied")
+			return
+		}
+		if len(allowNets) > 0 {
+			if !containsIp(allowNets, ip) {
+				rejectIp(c, name, clientIp, "ip not allowed")
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(allowCountries) == 0 && len(denyCountries) == 0 {
+			c.Next()
+			return
+		}
+
+		location, err := library.NewIpIntelLib(c).Lookup(clientIp)
+		if err != nil {
+			if !errors.Is(err, library.ErrIpNotFound) {
+				logger.New(c).Warn("ip_access_lookup_error", "group", name, "ip", clientIp, "err", err)
+			}
+			if ruleConf.CountryFailClosed {
+				rejectIp(c, name, clientIp, "country unknown")
+				return
+			}
+			c.Next()
+			return
+		}
+		c.Set(ipCountryCtxKey, location.CountryCode)
+		if _, denied := denyCountries[location.CountryCode]; denied {
+			rejectIp(c, name, clientIp, "country denied: "+location.CountryCode)
+			return
+		}
+		if _, allowed := allowCountries[location.CountryCode]; len(allowCountries) > 0 && !allowed {
+			rejectIp(c, name, clientIp, "country not allowed: "+location.CountryCode)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ipCountry 获取请求IP所属的国家, IpAccess 已经查询过时直接使用, 否则只查离线库
+func ipCountry(c *gin.Context) string {
+	if country := c.GetString(ipCountryCtxKey); country != "" {
+		return country
+	}
+	location, err := library.NewIpIntelLib(c).LookupOffline(c.ClientIP())
+	if err != nil {
+		return ""
+	}
+	return location.CountryCode
+}
+
+func rejectIp(c *gin.Context, group, ip, reason string) {
+	logger.New(c).Warn("ip_access_denied", "group", group, "ip", ip, "reason", reason)
+	app.NewResponse(c).Error(errcode.ErrForbidden)
+	c.Abort()
+}
+
+// mustParseCidrs 解析网段配置, 单个IP按 /32 或 /128 处理, 配置错误时让项目停止启动
+func mustParseCidrs(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			// ::ffff:1.2.3.4 这种IPv6写法的IPv4地址要按 /128 处理, 加 /32 会变成 ::/32 这个网段
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil && !strings.Contains(cidr, ":") {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func containsIp(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func countrySet(countries []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(countries))
+	for _, country := range countries {
+		set[strings.ToUpper(country)] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMustParseCidrs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidrs   []string
+		ip      string
+		want    bool
+		wantErr bool
+	}{
+		{name: "single ipv4", cidrs: []string{"127.0.0.1"}, ip: "127.0.0.1", want: true},
+		{name: "single ipv4 does not match neighbour", cidrs: []string{"127.0.0.1"}, ip: "127.0.0.2", want: false},
+		{name: "ipv4 network", cidrs: []string{"10.0.0.0/8"}, ip: "10.1.2.3", want: true},
+		{name: "outside ipv4 network", cidrs: []string{"172.16.0.0/12"}, ip: "172.32.0.1", want: false},
+		{name: "single ipv6", cidrs: []string{"::1"}, ip: "::1", want: true},
+		{name: "single ipv6 does not match neighbour", cidrs: []string{"::1"}, ip: "::2", want: false},
+		{name: "ipv6 network", cidrs: []string{"2001:db8::/32"}, ip: "2001:db8:1::1", want: true},
+		{name: "ipv4 network matches ipv4 mapped address", cidrs: []string{"192.168.0.0/16"}, ip: "::ffff:192.168.1.1", want: true},
+		{name: "ipv4 mapped single ip", cidrs: []string{"::ffff:127.0.0.1"}, ip: "127.0.0.1", want: true},
+		{name: "ipv4 mapped single ip is not a network", cidrs: []string{"::ffff:127.0.0.1"}, ip: "::1", want: false},
+		{name: "any of multiple entries", cidrs: []string{"10.0.0.0/8", "::1"}, ip: "::1", want: true},
+		{name: "empty list", cidrs: nil, ip: "127.0.0.1", want: false},
+		{name: "invalid ip", cidrs: []string{"localhost"}, wantErr: true},
+		{name: "invalid mask", cidrs: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantErr {
+					t.Fatalf("mustParseCidrs(%v) panic = %v, wantErr %v", tt.cidrs, r, tt.wantErr)
+				}
+			}()
+			nets := mustParseCidrs(tt.cidrs)
+			if got := containsIp(nets, net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("containsIp(%v, %s) = %v, want %v", tt.cidrs, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
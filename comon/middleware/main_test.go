@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/dal/cache"
+	"os"
+	"testing"
+)
+
+// testRedis 测试用的内存Redis, 用到Redis的中间件在这个Redis上测试
+var testRedis *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	testRedis = miniredis.NewMiniRedis()
+	testRedis.RequireAuth(config.Redis.Password)
+	if err := testRedis.Start(); err != nil {
+		panic(err)
+	}
+	config.Redis.Addr = testRedis.Addr()
+	cache.InitRedis()
+
+	code := m.Run()
+	testRedis.Close()
+	os.Exit(code)
+}
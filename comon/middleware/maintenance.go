@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/featureflag"
+	"net/http"
+)
+
+// Maintenance 维护模式中间件, 功能开关 maintenance:name 开启时路由组返回维护中的统一响应
+// 比如支付渠道故障时开启 maintenance:checkout, 不需要重新部署就能临时下线结算相关的接口
+// 路由组上的维护开关在认证之前执行, 拿不到用户, 对整个路由组是全开或全关: 只有总开关开启且没有设置灰度比例和用户名单
+// (或者灰度比例为100)时才进入维护状态; 需要按用户灰度时把它放在 AuthUser 之后, 只对单个路由生效
+func Maintenance(name string) gin.HandlerFunc {
+	flagName := featureflag.MaintenancePrefix + name
+	return func(c *gin.Context) {
+		// 跨域预检请求不受影响, 浏览器才能读到维护中的响应
+		if c.Request.Method != http.MethodOptions && featureflag.IsEnabled(c, flagName) {
+			app.NewResponse(c).Error(errcode.ErrMaintenance)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
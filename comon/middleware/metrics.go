@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"strconv"
+	"time"
+)
+
+// Metrics 记录接口的请求数和耗时
+// 标签 route 使用路由模板(比如 /user/:id), 没有匹配到路由的请求统一记为 unmatched, 避免扫描类请求让指标数量膨胀
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequestsTotal.WithLabelValues(route, c.Request.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
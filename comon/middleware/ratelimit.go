@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/ratelimit"
+	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/dal/cache"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 限流的维度, 只使用经过验证的身份, 请求头中未经验证的值可以随意更换, 不能作为限流的维度
+const (
+	rateLimitKeyByIP      = "ip"
+	rateLimitKeyByUser    = "user"     // 按用户限流, 需要放在 AuthUser 之后
+	rateLimitKeyByOpenApp = "open_app" // 按合作方应用限流, 需要放在 VerifySignature 之后
+)
+
+// Redis 限流出错后这段时间内直接使用进程内限流
+const rateLimitRedisCooldown = 10 * time.Second
+
+var (
+	rateLimiter     ratelimit.Limiter
+	rateLimiterOnce sync.Once
+)
+
+// getRateLimiter 限流优先使用Redis, 多个服务实例共享额度; Redis出错时降级为进程内限流, 冷却时间过后再尝试Redis
+func getRateLimiter() ratelimit.Limiter {
+	rateLimiterOnce.Do(func() {
+		rateLimiter = ratelimit.NewFallbackLimiter(
+			ratelimit.NewRedisLimiter(cache.Redis(), enum.REDIS_KEY_RATE_LIMIT_PREFIX),
+			ratelimit.NewMemoryLimiter(),
+			rateLimitRedisCooldown,
+		)
+	})
+	return rateLimiter
+}
+
+// RateLimit 限流中间件, 使用配置 rate_limit 中 name 对应的规则, 一般一个路由组对应一条规则
+// 按用户或者合作方应用限流时要放在对应的认证中间件之后, 取不到身份时按IP限流; 没有配置规则时不做限流
+func RateLimit(name string) gin.HandlerFunc {
+	ruleConf, ok := config.RateLimit[name]
+	if !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	switch ruleConf.KeyBy {
+	case "", rateLimitKeyByIP, rateLimitKeyByUser, rateLimitKeyByOpenApp:
+	default:
+		// 限流维度配置错误时阻止应用启动
+		panic("rate_limit." + name + ": unsupported key_by " + ruleConf.KeyBy)
+	}
+	rule := ratelimit.Rule{
+		Algorithm: ruleConf.Algorithm,
+		Limit:     ruleConf.Limit,
+		Window:    ruleConf.Window,
+		Burst:     ruleConf.Burst,
+	}
+
+	return func(c *gin.Context) {
+		key := name + ":" + rateLimitKey(c, ruleConf.KeyBy)
+		result, err := getRateLimiter().Allow(c, key, rule)
+		if err != nil {
+			// 限流器本身出错时放行请求, 不能因为限流组件影响正常业务
+			logger.New(c).Error("ratelimit_error", "key", key, "err", err)
+			c.Next()
+			return
+		}
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		// Limit 和 Policy 使用同一个额度, 令牌桶的额度是桶的容量, 窗口是令牌从空补满需要的时间
+		c.Header("RateLimit-Policy", strconv.Itoa(result.Limit)+";w="+strconv.Itoa(ceilSeconds(result.Window)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			app.NewResponse(c).Error(errcode.ErrTooManyRequests)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitKey 按限流维度取请求的标识, 取不到用户ID或合作方应用时按IP限流
+func rateLimitKey(c *gin.Context, keyBy string) string {
+	switch keyBy {
+	case rateLimitKeyByUser:
+		if userId := auth.GetUserId(c); userId != 0 {
+			return "user:" + strconv.FormatInt(userId, 10)
+		}
+	case rateLimitKeyByOpenApp:
+		if appKey := GetOpenAppKey(c); appKey != "" {
+			return "open_app:" + appKey
+		}
+	}
+	return rateLimitKeyByIP + ":" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       config.RateLimitRule
+		wantLimit  string
+		wantPolicy string
+	}{
+		{
+			// 令牌桶的额度是桶的容量, 窗口是令牌从空补满需要的时间
+			name:       "token bucket with burst",
+			rule:       config.RateLimitRule{Algorithm: "token_bucket", Limit: 2, Window: time.Minute, Burst: 4},
+			wantLimit:  "4",
+			wantPolicy: "4;w=120",
+		},
+		{
+			name:       "sliding window",
+			rule:       config.RateLimitRule{Algorithm: "sliding_window", Limit: 2, Window: time.Minute},
+			wantLimit:  "2",
+			wantPolicy: "2;w=60",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "test_" + t.Name()
+			if config.RateLimit == nil {
+				config.RateLimit = make(map[string]config.RateLimitRule)
+			}
+			config.RateLimit[name] = tt.rule
+			t.Cleanup(func() { delete(config.RateLimit, name) })
+			engine := gin.New()
+			engine.GET("/goods", RateLimit(name), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/goods", nil))
+				if got := w.Header().Get("RateLimit-Limit"); got != tt.wantLimit {
+					t.Errorf("request %d: RateLimit-Limit = %s, want %s", i, got, tt.wantLimit)
+				}
+				if got := w.Header().Get("RateLimit-Policy"); got != tt.wantPolicy {
+					t.Errorf("request %d: RateLimit-Policy = %s, want %s", i, got, tt.wantPolicy)
+				}
+			}
+		})
+	}
+}
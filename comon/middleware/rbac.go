@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/logic/domainservice"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sync"
+)
+
+// RequirePermission 权限校验中间件, 需要放在 AuthUser 之后使用
+// 用法: g.POST("order/refund", middleware.AuthUser(), middleware.RequirePermission("order:refund"), controller.RefundOrder)
+// 也可以通过 g.Use 作用于整个路由组
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := auth.GetUserId(c)
+		if userId == 0 {
+			app.NewResponse(c).Error(errcode.ErrToken)
+			c.Abort()
+			return
+		}
+		allowed, err := domainservice.NewRbacDomainSvc(c).HasPermission(userId, permission)
+		if err != nil {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+			c.Abort()
+			return
+		}
+		if !allowed {
+			logger.New(c).Warn("permission_denied", "userId", userId, "permission", permission)
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 路由审计: 检查每个路由的处理链中是否声明了权限
+// gin 不对外暴露路由的处理链, 审计时给每个路由发一个进程内的探测请求,
+// 由放在全局中间件第一位的 RouteAuditProbe 读取处理链后直接终止请求, 不会执行后面的中间件和业务逻辑
+
+// RouteAudit 单个路由的审计结果
+// 无法审计的路由 Unaudited 中记录原因, PermissionDeclared 为 false, 需要人工确认路由的权限
+type RouteAudit struct {
+	Method             string
+	Path               string
+	PermissionDeclared bool
+	Handlers           []string
+	Unaudited          string
+}
+
+type routeAuditCtxKey struct{}
+
+type routeAuditRecord struct {
+	fullPath string
+	handlers []string
+}
+
+var (
+	permissionHandlerName = runtime.FuncForPC(reflect.ValueOf(RequirePermission("")).Pointer()).Name()
+	routeParamPattern     = regexp.MustCompile(`[:*][^/]+`)
+
+	routeAudits   []RouteAudit
+	routeAuditsMu sync.RWMutex
+)
+
+// RouteAuditProbe 路由审计的探测中间件, 必须注册为第一个全局中间件
+// 探测请求的标记放在请求的 context 中, 外部请求无法伪造
+func RouteAuditProbe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if record, ok := c.Request.Context().Value(routeAuditCtxKey{}).(*routeAuditRecord); ok {
+			record.fullPath = c.FullPath()
+			record.handlers = c.HandlerNames()
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuditRoutes 审计引擎中注册的所有路由, 结果可以通过 GetRouteAudits 获取
+// 需要在所有路由注册完成后调用, 没有声明权限的路由和无法审计的路由分别记录一条警告日志
+func AuditRoutes(engine *gin.Engine) []RouteAudit {
+	audits := make([]RouteAudit, 0)
+	undeclared := make([]string, 0)
+	unaudited := make([]string, 0)
+	for _, route := range engine.Routes() {
+		if route.Method == http.MethodOptions {
+			// OPTIONS 路由是 CORS 预检请求的处理, 浏览器发送预检时不会携带认证信息, 不需要声明权限
+			continue
+		}
+		audit := auditRoute(engine, route)
+		if audit.Unaudited != "" {
+			unaudited = append(unaudited, route.Method+" "+route.Path+": "+audit.Unaudited)
+		} else if !audit.PermissionDeclared {
+			undeclared = append(undeclared, route.Method+" "+route.Path)
+		}
+		audits = append(audits, audit)
+	}
+	if len(undeclared) > 0 {
+		logger.New(context.Background()).Warn("routes_without_permission", "routes", undeclared)
+	}
+	if len(unaudited) > 0 {
+		logger.New(context.Background()).Warn("routes_not_audited", "routes", unaudited)
+	}
+	routeAuditsMu.Lock()
+	routeAudits = audits
+	routeAuditsMu.Unlock()
+
+	return audits
+}
+
+// auditRoute 给路由发送探测请求读取处理链, 无法审计时在 Unaudited 中记录原因
+func auditRoute(engine *gin.Engine, route gin.RouteInfo) RouteAudit {
+	audit := RouteAudit{Method: route.Method, Path: route.Path}
+	record := new(routeAuditRecord)
+	ctx := context.WithValue(context.Background(), routeAuditCtxKey{}, record)
+	path := routeParamPattern.ReplaceAllString(route.Path, "audit")
+	req, err := http.NewRequestWithContext(ctx, route.Method, path, nil)
+	if err != nil {
+		audit.Unaudited = "build probe request failed: " + err.Error()
+		return audit
+	}
+	engine.ServeHTTP(discardResponseWriter{header: http.Header{}}, req)
+	switch record.fullPath {
+	case route.Path:
+	case "":
+		// 路由在 RouteAuditProbe 注册之前注册(比如 /metrics), 或者被前面的中间件终止了, 探测中间件没有执行
+		audit.Unaudited = "RouteAuditProbe not executed"
+		return audit
+	default:
+		// 探测请求被路由到了其他路由上(比如静态路由和参数路由冲突)
+		audit.Unaudited = "probe routed to " + record.fullPath
+		return audit
+	}
+	audit.Handlers = record.handlers
+	for _, handler := range record.handlers {
+		if handler == permissionHandlerName {
+			audit.PermissionDeclared = true
+			break
+		}
+	}
+	return audit
+}
+
+// GetRouteAudits 获取最近一次路由审计的结果
+func GetRouteAudits() []RouteAudit {
+	routeAuditsMu.RLock()
+	defer routeAuditsMu.RUnlock()
+	return routeAudits
+}
+
+// discardResponseWriter 丢弃探测请求的响应
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardResponseWriter) WriteHeader(int) {}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+)
+
+func TestAuditRoutes(t *testing.T) {
+	engine := gin.New()
+	// 在探测中间件之前注册的路由, 探测中间件不会执行
+	engine.GET("/metrics", func(c *gin.Context) {})
+	engine.Use(RouteAuditProbe())
+	engine.POST("/orders/:id/refund", RequirePermission("order:refund"), func(c *gin.Context) {})
+	engine.GET("/orders/:id", func(c *gin.Context) {})
+	// 静态路由和参数路由冲突, 参数路由的探测请求会落到静态路由上
+	engine.GET("/orders/audit", RequirePermission("order:audit"), func(c *gin.Context) {})
+	engine.OPTIONS("/orders/:id", func(c *gin.Context) {})
+
+	audits := make(map[string]RouteAudit)
+	for _, audit := range AuditRoutes(engine) {
+		audits[audit.Method+" "+audit.Path] = audit
+	}
+	tests := []struct {
+		route         string
+		wantDeclared  bool
+		wantUnaudited string
+	}{
+		{route: "POST /orders/:id/refund", wantDeclared: true},
+		{route: "GET /orders/audit", wantDeclared: true},
+		{route: "GET /metrics", wantUnaudited: "RouteAuditProbe not executed"},
+		{route: "GET /orders/:id", wantUnaudited: "probe routed to /orders/audit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			audit, ok := audits[tt.route]
+			if !ok {
+				t.Fatalf("route %s missing from audits", tt.route)
+			}
+			if audit.PermissionDeclared != tt.wantDeclared || audit.Unaudited != tt.wantUnaudited {
+				t.Errorf("audit = declared %v unaudited %q, want declared %v unaudited %q",
+					audit.PermissionDeclared, audit.Unaudited, tt.wantDeclared, tt.wantUnaudited)
+			}
+		})
+	}
+	if _, ok := audits[http.MethodOptions+" /orders/:id"]; ok {
+		t.Error("OPTIONS route should be skipped")
+	}
+	if got := len(GetRouteAudits()); got != len(audits) {
+		t.Errorf("GetRouteAudits() returned %d audits, want %d", got, len(audits))
+	}
+}
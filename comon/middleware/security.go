@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/config"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsDefaultPolicy 没有单独配置跨域策略的路由组使用的策略名
+const corsDefaultPolicy = "default"
+
+// Cors 跨域中间件, 使用配置 cors 中 name 对应的策略, 没有配置时使用 default 策略
+// gin 只有匹配到路由时才会执行路由组的中间件, 所以路由组还要注册一个 OPTIONS 路由来接收预检请求:
+//
+//	g.Use(middleware.Cors("building"))
+//	g.OPTIONS("*path", middleware.CorsPreflight)
+//
+// 需要放在认证中间件之前, 预检请求不携带Token
+func Cors(name string) gin.HandlerFunc {
+	policy, ok := config.Cors[name]
+	if !ok {
+		policy, ok = config.Cors[corsDefaultPolicy]
+	}
+	if !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	if policy.AllowCredentials && slices.Contains(policy.AllowOrigins, "*") {
+		// 允许任意Origin携带凭证访问相当于关闭了同源策略, 配置错误时阻止应用启动
+		panic("cors." + name + ": allow_origins \"*\" can not be used with allow_credentials")
+	}
+	allowMethods := strings.Join(policy.AllowMethods, ", ")
+	allowHeaders := strings.Join(policy.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(policy.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		// 响应随Origin变化, 没有Origin的请求也要设置, 否则共享缓存会把不带跨域响应头的响应返回给跨域请求
+		c.Writer.Header().Add("Vary", "Origin")
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !corsOriginAllowed(policy.AllowOrigins, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// 不设置跨域响应头, 由浏览器拦截响应
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if policy.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				c.Header("Access-Control-Allow-Headers", allowHeaders)
+			} else {
+				c.Header("Access-Control-Allow-Headers", c.GetHeader("Access-Control-Request-Headers"))
+			}
+			if policy.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if exposeHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+// CorsPreflight 接收预检请求的路由处理函数, 预检请求的响应由 Cors 中间件完成
+func CorsPreflight(c *gin.Context) {
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// corsOriginAllowed 判断Origin是否被允许, 支持 * 和 https://*.go-mall.com 形式的通配子域名
+func corsOriginAllowed(allowOrigins []string, origin string) bool {
+	for _, allowed := range allowOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		idx := strings.Index(allowed, "*.")
+		if idx < 0 {
+			continue
+		}
+		prefix, suffix := strings.ToLower(allowed[:idx]), strings.ToLower(allowed[idx+1:])
+		lowerOrigin := strings.ToLower(origin)
+		if strings.HasPrefix(lowerOrigin, prefix) && strings.HasSuffix(lowerOrigin, suffix) {
+			subdomain := lowerOrigin[len(prefix) : len(lowerOrigin)-len(suffix)]
+			if subdomain != "" && !strings.ContainsAny(subdomain, "/:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// securityHeadersOff 配置为这个值的安全响应头不设置
+const securityHeadersOff = "off"
+
+// SecurityHeaders 安全响应头中间件, 没有配置的响应头使用按环境区分的默认值
+func SecurityHeaders() gin.HandlerFunc {
+	headers := defaultSecurityHeaders(config.App.Env)
+	if conf := config.Security; conf != nil {
+		overrides := map[string]string{
+			"Strict-Transport-Security": conf.HSTS,
+			"X-Content-Type-Options":    conf.ContentTypeOptions,
+			"X-Frame-Options":           conf.FrameOptions,
+			"Content-Security-Policy":   conf.ContentSecurityPolicy,
+			"Referrer-Policy":           conf.ReferrerPolicy,
+		}
+		for name, value := range overrides {
+			switch value {
+			case "":
+			case securityHeadersOff:
+				delete(headers, name)
+			default:
+				headers[name] = value
+			}
+		}
+	}
+
+	return func(c *gin.Context) {
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Next()
+	}
+}
+
+// defaultSecurityHeaders 各环境默认的安全响应头, 只有生产环境默认开启HSTS
+func defaultSecurityHeaders(env string) map[string]string {
+	headers := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+		"Referrer-Policy":         "no-referrer",
+	}
+	if env == enum.ModeProd {
+		headers["Strict-Transport-Security"] = "max-age=31536000; includeSubDomains"
+	}
+	return headers
+}
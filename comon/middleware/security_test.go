@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/config"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCorsOriginAllowed(t *testing.T) {
+	allowOrigins := []string{"https://www.go-mall.com", "https://*.go-mall.com", "http://localhost:3000"}
+	tests := []struct {
+		name         string
+		allowOrigins []string
+		origin       string
+		want         bool
+	}{
+		{name: "exact match", allowOrigins: allowOrigins, origin: "https://www.go-mall.com", want: true},
+		{name: "exact match is case insensitive", allowOrigins: allowOrigins, origin: "HTTPS://WWW.GO-MALL.COM", want: true},
+		{name: "exact match with port", allowOrigins: allowOrigins, origin: "http://localhost:3000", want: true},
+		{name: "different port", allowOrigins: allowOrigins, origin: "http://localhost:3001", want: false},
+		{name: "wildcard subdomain", allowOrigins: allowOrigins, origin: "https://m.go-mall.com", want: true},
+		{name: "wildcard nested subdomain", allowOrigins: allowOrigins, origin: "https://a.b.go-mall.com", want: true},
+		{name: "wildcard needs a subdomain", allowOrigins: allowOrigins, origin: "https://go-mall.com", want: false},
+		{name: "wildcard scheme must match", allowOrigins: allowOrigins, origin: "http://m.go-mall.com", want: false},
+		{name: "suffix without dot", allowOrigins: allowOrigins, origin: "https://evilgo-mall.com", want: false},
+		{name: "domain as subdomain of attacker", allowOrigins: allowOrigins, origin: "https://m.go-mall.com.evil.com", want: false},
+		{name: "path in subdomain part", allowOrigins: allowOrigins, origin: "https://evil.com/.go-mall.com", want: false},
+		{name: "port in subdomain part", allowOrigins: allowOrigins, origin: "https://evil.com:443.go-mall.com", want: false},
+		{name: "any origin", allowOrigins: []string{"*"}, origin: "https://anything.example", want: true},
+		{name: "no allowed origins", allowOrigins: nil, origin: "https://www.go-mall.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := corsOriginAllowed(tt.allowOrigins, tt.origin); got != tt.want {
+				t.Errorf("corsOriginAllowed(%v, %q) = %v, want %v", tt.allowOrigins, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorsRejectsAnyOriginWithCredentials(t *testing.T) {
+	originCors := config.Cors
+	config.Cors = map[string]config.CorsPolicy{
+		"bad": {AllowOrigins: []string{"https://www.go-mall.com", "*"}, AllowCredentials: true},
+	}
+	t.Cleanup(func() { config.Cors = originCors })
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Cors() did not panic for allow_origins \"*\" with allow_credentials")
+		}
+	}()
+	Cors("bad")
+}
+
+func TestCorsResponseHeaders(t *testing.T) {
+	originCors := config.Cors
+	config.Cors = map[string]config.CorsPolicy{
+		"test": {
+			AllowOrigins:     []string{"https://*.go-mall.com"},
+			AllowMethods:     []string{http.MethodGet, http.MethodPost},
+			AllowHeaders:     []string{"Authorization"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		},
+	}
+	t.Cleanup(func() { config.Cors = originCors })
+
+	engine := gin.New()
+	g := engine.Group("/", Cors("test"))
+	g.OPTIONS("*path", CorsPreflight)
+	g.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	tests := []struct {
+		name            string
+		method          string
+		origin          string
+		preflight       bool
+		wantStatus      int
+		wantAllowOrigin string
+	}{
+		{name: "same origin request", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "allowed origin", method: http.MethodGet, origin: "https://m.go-mall.com", wantStatus: http.StatusOK, wantAllowOrigin: "https://m.go-mall.com"},
+		{name: "disallowed origin", method: http.MethodGet, origin: "https://evil.com", wantStatus: http.StatusOK},
+		{name: "allowed preflight", method: http.MethodOptions, origin: "https://m.go-mall.com", preflight: true, wantStatus: http.StatusNoContent, wantAllowOrigin: "https://m.go-mall.com"},
+		{name: "disallowed preflight", method: http.MethodOptions, origin: "https://evil.com", preflight: true, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/ping", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			// 无论是否跨域都要告诉共享缓存响应随 Origin 变化
+			if !slices.Contains(w.Header().Values("Vary"), "Origin") {
+				t.Errorf("Vary = %v, want Origin", w.Header().Values("Vary"))
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/signature"
+	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/dal/cache"
+	"github/lhh-gh/go-mall/logic/do"
+	"github/lhh-gh/go-mall/logic/domainservice"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSignatureTimestampSkew = 5 * time.Minute
+	defaultSignatureMaxBodySize   = 1 << 20
+
+	openAppKeyCtxKey = "open_app_key"
+	openAppIdCtxKey  = "open_app_id"
+)
+
+// lookupOpenApp 按AppKey查询合作方应用, 单元测试中替换成不需要连接数据库的实现
+var lookupOpenApp = func(ctx context.Context, appKey string) (*do.OpenApp, error) {
+	return domainservice.NewOpenAppDomainSvc(ctx).GetOpenApp(appKey)
+}
+
+// VerifySignature 开放接口的请求签名校验中间件, 签名规则见 signature 包
+// 依次校验: 签名请求头是否完整、时间戳是否在允许的偏差内、应用是否存在、签名是否正确、应用是否启用、随机串是否被使用过
+// 应用的状态在签名校验通过后才返回给调用方, 防止未认证的请求通过错误码探测AppKey是否存在以及是否启用
+// 随机串在签名校验通过后才占用, 防止伪造的请求把合作方的随机串提前占掉
+func VerifySignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appKey := c.GetHeader(signature.HeaderAppKey)
+		nonce := c.GetHeader(signature.HeaderNonce)
+		sign := c.GetHeader(signature.HeaderSignature)
+		timestamp, err := strconv.ParseInt(c.GetHeader(signature.HeaderTimestamp), 10, 64)
+		if appKey == "" || nonce == "" || sign == "" || err != nil {
+			abortSignature(c, errcode.ErrSignature.WithCause(errors.New("missing signature headers")))
+			return
+		}
+		skew, maxBodySize := signatureLimits()
+		if d := time.Since(time.Unix(timestamp, 0)); d > skew || d < -skew {
+			abortSignature(c, errcode.ErrSignature.WithCause(errors.New("timestamp out of range")))
+			return
+		}
+
+		openApp, err := lookupOpenApp(c, appKey)
+		if err != nil {
+			abortSignature(c, errcode.ErrServer.WithCause(err))
+			return
+		}
+		if openApp == nil {
+			abortSignature(c, errcode.ErrSignature.WithCause(errors.New("unknown app key")))
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodySize+1))
+		if err != nil {
+			abortSignature(c, errcode.ErrParams.WithDetail(err))
+			return
+		}
+		if int64(len(body)) > maxBodySize {
+			abortSignature(c, errcode.ErrParams.WithDetail(errors.New("request body too large")))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		payload := &signature.Payload{
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Query:     c.Request.URL.Query(),
+			BodyHash:  signature.BodyHash(body),
+			Timestamp: timestamp,
+			Nonce:     nonce,
+		}
+		if !signature.Verify(openApp.AppSecret, payload, sign) {
+			abortSignature(c, errcode.ErrSignature.WithCause(errors.New("signature mismatch")))
+			return
+		}
+		if openApp.Status != enum.OpenAppStatusEnabled {
+			abortSignature(c, errcode.ErrOpenAppDisabled)
+			return
+		}
+
+		acquired, err := cache.AcquireOpenApiNonce(c, appKey, nonce, 2*skew)
+		if err != nil {
+			abortSignature(c, errcode.ErrServer.WithCause(err))
+			return
+		}
+		if !acquired {
+			abortSignature(c, errcode.ErrSignature.WithCause(errors.New("replayed nonce")))
+			return
+		}
+
+		c.Set(openAppKeyCtxKey, appKey)
+		c.Set(openAppIdCtxKey, openApp.Id)
+		c.Next()
+	}
+}
+
+// GetOpenAppKey 获取通过签名校验的合作方应用的AppKey
+func GetOpenAppKey(ctx context.Context) string {
+	appKey, _ := ctx.Value(openAppKeyCtxKey).(string)
+	return appKey
+}
+
+// GetOpenAppId 获取通过签名校验的合作方应用的ID
+func GetOpenAppId(ctx context.Context) int64 {
+	appId, _ := ctx.Value(openAppIdCtxKey).(int64)
+	return appId
+}
+
+func signatureLimits() (skew time.Duration, maxBodySize int64) {
+	skew, maxBodySize = defaultSignatureTimestampSkew, defaultSignatureMaxBodySize
+	if config.OpenApi != nil {
+		if config.OpenApi.TimestampSkew > 0 {
+			skew = config.OpenApi.TimestampSkew
+		}
+		if config.OpenApi.MaxBodySize > 0 {
+			maxBodySize = config.OpenApi.MaxBodySize
+		}
+	}
+	return
+}
+
+func abortSignature(c *gin.Context, err *errcode.AppError) {
+	logger.New(c).Warn("open_api_signature_rejected", "appKey", c.GetHeader(signature.HeaderAppKey), "err", err)
+	app.NewResponse(c).Error(err)
+	c.Abort()
+}
@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/signature"
+	"github/lhh-gh/go-mall/logic/do"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAppSecret = "test-secret"
+
+var testOpenApps = map[string]*do.OpenApp{
+	"enabled-app":  {Id: 1, AppKey: "enabled-app", AppSecret: testAppSecret, Status: enum.OpenAppStatusEnabled},
+	"disabled-app": {Id: 2, AppKey: "disabled-app", AppSecret: testAppSecret, Status: enum.OpenAppStatusDisabled},
+	"other-app":    {Id: 3, AppKey: "other-app", AppSecret: testAppSecret, Status: enum.OpenAppStatusEnabled},
+}
+
+// newSignatureEngine 注册一个使用验签中间件的路由, 应用从 testOpenApps 中查询, handler 返回被调用的次数
+func newSignatureEngine(t *testing.T) (*gin.Engine, *int32) {
+	origin := lookupOpenApp
+	lookupOpenApp = func(_ context.Context, appKey string) (*do.OpenApp, error) {
+		if openApp, ok := testOpenApps[appKey]; ok {
+			copied := *openApp
+			return &copied, nil
+		}
+		return nil, nil
+	}
+	t.Cleanup(func() { lookupOpenApp = origin })
+
+	calls := new(int32)
+	engine := gin.New()
+	engine.POST("/open/orders", VerifySignature(), func(c *gin.Context) {
+		atomic.AddInt32(calls, 1)
+		if GetOpenAppKey(c) == "" || GetOpenAppId(c) == 0 {
+			c.String(http.StatusInternalServerError, "open app not set")
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	return engine, calls
+}
+
+type signedRequest struct {
+	appKey    string
+	secret    string
+	timestamp int64
+	nonce     string
+	body      string
+	// tamper 签名后修改请求, 模拟请求在传输中被篡改
+	tamper func(req *http.Request)
+}
+
+func newSignedRequest(t *testing.T, appKey string) *signedRequest {
+	return &signedRequest{
+		appKey:    appKey,
+		secret:    testAppSecret,
+		timestamp: time.Now().Unix(),
+		nonce:     fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano()),
+		body:      `{"order_no":"20240101"}`,
+	}
+}
+
+func (sr *signedRequest) do(engine *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/open/orders?b=2&a=1", strings.NewReader(sr.body))
+	payload := &signature.Payload{
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Query:     req.URL.Query(),
+		BodyHash:  signature.BodyHash([]byte(sr.body)),
+		Timestamp: sr.timestamp,
+		Nonce:     sr.nonce,
+	}
+	req.Header.Set(signature.HeaderAppKey, sr.appKey)
+	req.Header.Set(signature.HeaderTimestamp, strconv.FormatInt(sr.timestamp, 10))
+	req.Header.Set(signature.HeaderNonce, sr.nonce)
+	req.Header.Set(signature.HeaderSignature, signature.Sign(sr.secret, payload))
+	if sr.tamper != nil {
+		sr.tamper(req)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func responseCode(t *testing.T, w *httptest.ResponseRecorder) int {
+	t.Helper()
+	var body struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response %q error: %v", w.Body.String(), err)
+	}
+	return body.Code
+}
+
+func TestVerifySignature(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(sr *signedRequest)
+		wantCode int // 0 表示请求通过验签
+	}{
+		{name: "valid request"},
+		{
+			name:     "missing signature headers",
+			modify:   func(sr *signedRequest) { sr.tamper = func(req *http.Request) { req.Header.Del(signature.HeaderNonce) } },
+			wantCode: errcode.ErrSignature.Code(),
+		},
+		{
+			name: "timestamp out of range",
+			modify: func(sr *signedRequest) {
+				sr.timestamp -= int64((defaultSignatureTimestampSkew + time.Minute).Seconds())
+			},
+			wantCode: errcode.ErrSignature.Code(),
+		},
+		{
+			name:     "unknown app key",
+			modify:   func(sr *signedRequest) { sr.appKey = "unknown-app" },
+			wantCode: errcode.ErrSignature.Code(),
+		},
+		{
+			name:     "wrong secret",
+			modify:   func(sr *signedRequest) { sr.secret = "other-secret" },
+			wantCode: errcode.ErrSignature.Code(),
+		},
+		{
+			name: "tampered query",
+			modify: func(sr *signedRequest) {
+				sr.tamper = func(req *http.Request) { req.URL.RawQuery = "a=1&b=3" }
+			},
+			wantCode: errcode.ErrSignature.Code(),
+		},
+		{
+			name:     "body too large",
+			modify:   func(sr *signedRequest) { sr.body = strings.Repeat("x", defaultSignatureMaxBodySize+1) },
+			wantCode: errcode.ErrParams.Code(),
+		},
+		{
+			name:     "disabled app with valid signature",
+			modify:   func(sr *signedRequest) { sr.appKey = "disabled-app" },
+			wantCode: errcode.ErrOpenAppDisabled.Code(),
+		},
+		{
+			// 停用状态在验签通过后才返回, 未认证的请求探测不到应用是否停用
+			name: "disabled app with wrong secret",
+			modify: func(sr *signedRequest) {
+				sr.appKey = "disabled-app"
+				sr.secret = "other-secret"
+			},
+			wantCode: errcode.ErrSignature.Code(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, calls := newSignatureEngine(t)
+			sr := newSignedRequest(t, "enabled-app")
+			if tt.modify != nil {
+				tt.modify(sr)
+			}
+			w := sr.do(engine)
+			if tt.wantCode == 0 {
+				if w.Code != http.StatusOK || w.Body.String() != "ok" {
+					t.Fatalf("response = %d %q, want 200 ok", w.Code, w.Body.String())
+				}
+				return
+			}
+			if got := responseCode(t, w); got != tt.wantCode {
+				t.Errorf("code = %d, want %d", got, tt.wantCode)
+			}
+			if got := atomic.LoadInt32(calls); got != 0 {
+				t.Errorf("handler called %d times, want 0", got)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRejectsReplayedNonce(t *testing.T) {
+	engine, calls := newSignatureEngine(t)
+	sr := newSignedRequest(t, "enabled-app")
+	if w := sr.do(engine); w.Code != http.StatusOK {
+		t.Fatalf("first response = %d %q, want 200", w.Code, w.Body.String())
+	}
+	if w := sr.do(engine); responseCode(t, w) != errcode.ErrSignature.Code() {
+		t.Errorf("replayed response = %d %q, want signature error", w.Code, w.Body.String())
+	}
+	// 同一个随机串换一个应用不算重放
+	other := *sr
+	other.appKey = "other-app"
+	if w := other.do(engine); w.Code != http.StatusOK {
+		t.Errorf("same nonce for another app = %d %q, want 200", w.Code, w.Body.String())
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("handler called %d times, want 2", got)
+	}
+}
+
+func TestVerifySignatureForgedRequestKeepsNonce(t *testing.T) {
+	// 签名不正确的请求不能占用随机串, 否则攻击者可以提前占掉合作方的随机串
+	engine, calls := newSignatureEngine(t)
+	forged := newSignedRequest(t, "enabled-app")
+	forged.secret = "other-secret"
+	if w := forged.do(engine); responseCode(t, w) != errcode.ErrSignature.Code() {
+		t.Fatalf("forged response = %d %q, want signature error", w.Code, w.Body.String())
+	}
+	genuine := *forged
+	genuine.secret = testAppSecret
+	if w := genuine.do(engine); w.Code != http.StatusOK {
+		t.Errorf("genuine response = %d %q, want 200", w.Code, w.Body.String())
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("handler called %d times, want 1", got)
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/app"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"strings"
+	"time"
+)
+
+// Timeout 接口超时中间件, 超时时间按路由从配置 timeout 中读取
+// 给请求的 Context 设置截止时间, 业务代码把 gin.Context 作为 ctx 传给 DB().WithContext、缓存和 httptool 时,
+// 这些调用会在截止时间到达后被取消(需要开启 engine.ContextWithFallback)
+//
+// 中间件不会中断处理函数: 处理函数仍然在 gin 的 goroutine 中同步执行, 超时错误要等处理函数返回后才写给客户端。
+// 只有使用请求 ctx 的数据库、Redis、HTTP调用会在超时后返回错误, 处理函数中不使用 ctx 的调用(比如 time.Sleep、
+// 传 context.Background() 的调用、CPU密集的计算)会一直执行完, 客户端也要一直等到处理函数返回。
+// 处理函数超时后写出的响应会被丢弃, 统一由中间件返回超时错误, 不会重复写响应
+func Timeout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := routeTimeout(c.FullPath())
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// 处理函数设置的响应头在超时后要丢弃, 只保留之前的中间件设置的
+		headerSnapshot := c.Writer.Header().Clone()
+		writer := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = writer
+		// 处理函数panic时也要还原, 否则外层的 GinPanicRecovery 写出的错误响应会被丢弃
+		defer func() { c.Writer = writer.ResponseWriter }()
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		// 没有超时, 或者超时前处理函数已经开始写响应时, 保留处理函数的响应
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) || c.Writer.Written() {
+			return
+		}
+		logger.New(c).Warn("request_timeout", "path", c.FullPath(), "timeout", timeout.String())
+		header := c.Writer.Header()
+		for key := range header {
+			delete(header, key)
+		}
+		for key, values := range headerSnapshot {
+			header[key] = values
+		}
+		app.NewResponse(c).Error(errcode.ErrTimeout)
+		c.Abort()
+	}
+}
+
+// routeTimeout 读取路由的超时时间, 路由没有单独配置时使用默认值
+func routeTimeout(fullPath string) time.Duration {
+	if config.Timeout == nil {
+		return 0
+	}
+	if timeout, ok := config.Timeout.Routes[strings.ToLower(fullPath)]; ok {
+		return timeout
+	}
+	return config.Timeout.Default
+}
+
+// timeoutWriter 请求超时后丢弃处理函数写出的响应
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx       context.Context
+	discarded bool
+}
+
+func (w *timeoutWriter) timedOut() bool {
+	if w.ctx.Err() != nil && !w.ResponseWriter.Written() {
+		w.discarded = true
+	}
+	return w.discarded
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.timedOut() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.timedOut() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if w.timedOut() {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.timedOut() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	if w.timedOut() {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
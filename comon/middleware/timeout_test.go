@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	origin := config.Timeout.Routes
+	config.Timeout.Routes = map[string]time.Duration{"/slow": 20 * time.Millisecond}
+	t.Cleanup(func() { config.Timeout.Routes = origin })
+
+	tests := []struct {
+		name        string
+		handler     func(c *gin.Context)
+		wantCode    int // 响应体中的业务码, 0 表示处理函数的响应
+		wantElapsed time.Duration
+	}{
+		{
+			name:    "handler finishes in time",
+			handler: func(c *gin.Context) { c.String(http.StatusOK, "ok") },
+		},
+		{
+			// 使用请求ctx的调用在超时后返回, 处理函数超时后写的响应被丢弃
+			name: "handler honours context",
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+				c.Header("X-Handler", "1")
+				c.String(http.StatusOK, "late")
+			},
+			wantCode: errcode.ErrTimeout.Code(),
+		},
+		{
+			// 不使用ctx的处理函数不会被中断, 超时错误在处理函数返回后才写出
+			name: "handler ignores context",
+			handler: func(c *gin.Context) {
+				time.Sleep(100 * time.Millisecond)
+				c.String(http.StatusOK, "late")
+			},
+			wantCode:    errcode.ErrTimeout.Code(),
+			wantElapsed: 100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.GET("/slow", Timeout(), tt.handler)
+			w := httptest.NewRecorder()
+			start := time.Now()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			if elapsed := time.Since(start); elapsed < tt.wantElapsed {
+				t.Errorf("request returned after %v, want at least %v", elapsed, tt.wantElapsed)
+			}
+			if tt.wantCode == 0 {
+				if w.Code != http.StatusOK || w.Body.String() != "ok" {
+					t.Errorf("response = %d %q, want 200 ok", w.Code, w.Body.String())
+				}
+				return
+			}
+			if got := responseCode(t, w); got != tt.wantCode {
+				t.Errorf("code = %d, want %d", got, tt.wantCode)
+			}
+			if w.Header().Get("X-Handler") != "" {
+				t.Error("header set by handler after timeout was not discarded")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// 支持的限流算法
+const (
+	AlgorithmTokenBucket   = "token_bucket"   // 令牌桶, 允许一定程度的突发流量
+	AlgorithmSlidingWindow = "sliding_window" // 滑动窗口, 严格限制任意窗口内的请求数
+)
+
+// Rule 限流规则
+type Rule struct {
+	Algorithm string        // 限流算法, 默认令牌桶
+	Limit     int           // 每个 Window 内允许的请求数, 令牌桶算法中表示 Window 内补充的令牌数
+	Window    time.Duration // 时间窗口
+	Burst     int           // 令牌桶容量, 为0时等于 Limit, 滑动窗口算法不使用
+}
+
+func (r Rule) capacity() int {
+	if r.Algorithm == AlgorithmSlidingWindow || r.Burst <= 0 {
+		return r.Limit
+	}
+	return r.Burst
+}
+
+// quotaWindow 容量对应的时间窗口, 令牌桶算法中是令牌从空补满需要的时间
+// 和 capacity 一起描述额度, 令牌桶的容量大于 Limit 时窗口按比例放大, 长期的速率仍然是每个 Window 内 Limit 个请求
+func (r Rule) quotaWindow() time.Duration {
+	if r.Algorithm == AlgorithmSlidingWindow {
+		return r.Window
+	}
+	return time.Duration(int64(r.Window) * int64(r.capacity()) / int64(r.Limit))
+}
+
+// Result 单次限流判断的结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // Window 内允许的请求数, 令牌桶算法中是桶的容量
+	Window     time.Duration // Limit 对应的时间窗口
+	Remaining  int           // 剩余可用的请求数
+	ResetAfter time.Duration // 多久后额度完全恢复
+	RetryAfter time.Duration // 被限流时多久后可以重试
+}
+
+// Limiter 限流器
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (*Result, error)
+}
@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"testing"
+	"time"
+)
+
+// limiterClock 测试中控制限流器使用的当前时间
+type limiterClock func(now time.Time)
+
+func newTestMemoryLimiter(t *testing.T) (Limiter, limiterClock) {
+	l := NewMemoryLimiter().(*memoryLimiter)
+	var now time.Time
+	l.now = func() time.Time { return now }
+	return l, func(at time.Time) { now = at }
+}
+
+func newTestRedisLimiter(t *testing.T) (Limiter, limiterClock) {
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	// 限流脚本使用 Redis 的 TIME 作为当前时间
+	return NewRedisLimiter(client, "ratelimit:"), m.SetTime
+}
+
+var testLimiters = map[string]func(t *testing.T) (Limiter, limiterClock){
+	"memory": newTestMemoryLimiter,
+	"redis":  newTestRedisLimiter,
+}
+
+func TestLimiterAllow(t *testing.T) {
+	type step struct {
+		after         time.Duration // 相对第一次请求经过的时间
+		wantAllowed   bool
+		wantRemaining int
+	}
+	tests := []struct {
+		name       string
+		rule       Rule
+		wantLimit  int
+		wantWindow time.Duration
+		steps      []step
+	}{
+		{
+			name:       "token bucket allows burst then refills",
+			rule:       Rule{Algorithm: AlgorithmTokenBucket, Limit: 2, Window: time.Minute, Burst: 3},
+			wantLimit:  3,
+			wantWindow: 90 * time.Second,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 2},
+				{wantAllowed: true, wantRemaining: 1},
+				{wantAllowed: true, wantRemaining: 0},
+				{wantAllowed: false, wantRemaining: 0},
+				{after: 29 * time.Second, wantAllowed: false, wantRemaining: 0},
+				// 每30秒补充一个令牌
+				{after: 30 * time.Second, wantAllowed: true, wantRemaining: 0},
+				{after: 90 * time.Second, wantAllowed: true, wantRemaining: 1},
+			},
+		},
+		{
+			name:       "token bucket without burst",
+			rule:       Rule{Limit: 2, Window: time.Minute},
+			wantLimit:  2,
+			wantWindow: time.Minute,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 1},
+				{wantAllowed: true, wantRemaining: 0},
+				{wantAllowed: false, wantRemaining: 0},
+			},
+		},
+		{
+			name:       "sliding window limits requests in any window",
+			rule:       Rule{Algorithm: AlgorithmSlidingWindow, Limit: 2, Window: time.Minute, Burst: 10},
+			wantLimit:  2,
+			wantWindow: time.Minute,
+			steps: []step{
+				{wantAllowed: true, wantRemaining: 1},
+				{after: 30 * time.Second, wantAllowed: true, wantRemaining: 0},
+				{after: 59 * time.Second, wantAllowed: false, wantRemaining: 0},
+				// 第一个请求移出窗口, 第二个请求还在窗口内
+				{after: 61 * time.Second, wantAllowed: true, wantRemaining: 0},
+				{after: 91 * time.Second, wantAllowed: true, wantRemaining: 0},
+				{after: 181 * time.Second, wantAllowed: true, wantRemaining: 1},
+			},
+		},
+	}
+	for limiterName, newLimiter := range testLimiters {
+		for _, tt := range tests {
+			t.Run(limiterName+"/"+tt.name, func(t *testing.T) {
+				limiter, setNow := newLimiter(t)
+				start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				for i, s := range tt.steps {
+					setNow(start.Add(s.after))
+					result, err := limiter.Allow(context.Background(), "user:1", tt.rule)
+					if err != nil {
+						t.Fatalf("step %d: Allow() error = %v", i, err)
+					}
+					if result.Allowed != s.wantAllowed || result.Remaining != s.wantRemaining {
+						t.Fatalf("step %d: allowed = %v remaining = %d, want %v %d", i, result.Allowed, result.Remaining, s.wantAllowed, s.wantRemaining)
+					}
+					if result.Limit != tt.wantLimit || result.Window != tt.wantWindow {
+						t.Errorf("step %d: limit = %d window = %v, want %d %v", i, result.Limit, result.Window, tt.wantLimit, tt.wantWindow)
+					}
+					if !result.Allowed && (result.RetryAfter <= 0 || result.RetryAfter > tt.rule.Window) {
+						t.Errorf("step %d: retry after = %v, want in (0, %v]", i, result.RetryAfter, tt.rule.Window)
+					}
+					if result.ResetAfter < 0 || result.ResetAfter > tt.wantWindow {
+						t.Errorf("step %d: reset after = %v, want in [0, %v]", i, result.ResetAfter, tt.wantWindow)
+					}
+				}
+			})
+		}
+		t.Run(limiterName+"/invalid rule", func(t *testing.T) {
+			limiter, _ := newLimiter(t)
+			if _, err := limiter.Allow(context.Background(), "user:1", Rule{Limit: 0, Window: time.Second}); err == nil {
+				t.Error("Allow() with zero limit returned no error")
+			}
+		})
+	}
+}
+
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	rule := Rule{Limit: 1, Window: time.Minute}
+	for limiterName, newLimiter := range testLimiters {
+		t.Run(limiterName, func(t *testing.T) {
+			limiter, setNow := newLimiter(t)
+			setNow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			for _, key := range []string{"user:1", "user:2"} {
+				result, err := limiter.Allow(context.Background(), key, rule)
+				if err != nil || !result.Allowed {
+					t.Errorf("Allow(%s) = %+v, %v, want allowed", key, result, err)
+				}
+			}
+		})
+	}
+}
+
+type stubLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *stubLimiter) Allow(ctx context.Context, key string, rule Rule) (*Result, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &Result{Allowed: true, Limit: rule.Limit}, nil
+}
+
+func TestFallbackLimiterCooldown(t *testing.T) {
+	primary := &stubLimiter{err: errors.New("redis down")}
+	fallback := &stubLimiter{}
+	limiter := NewFallbackLimiter(primary, fallback, time.Minute).(*fallbackLimiter)
+	rule := Rule{Limit: 1, Window: time.Second}
+
+	for i := 0; i < 3; i++ {
+		if result, err := limiter.Allow(context.Background(), "user:1", rule); err != nil || !result.Allowed {
+			t.Fatalf("request %d: Allow() = %+v, %v, want fallback result", i, result, err)
+		}
+	}
+	// 第一次出错后冷却时间内不再访问 primary
+	if primary.calls != 1 || fallback.calls != 3 {
+		t.Fatalf("primary calls = %d fallback calls = %d, want 1 and 3", primary.calls, fallback.calls)
+	}
+
+	// 冷却时间过后重新尝试 primary, primary 恢复后不再使用 fallback
+	limiter.skipUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	primary.err = nil
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Allow(context.Background(), "user:1", rule); err != nil {
+			t.Fatalf("Allow() after cooldown error = %v", err)
+		}
+	}
+	if primary.calls != 3 || fallback.calls != 3 {
+		t.Errorf("primary calls = %d fallback calls = %d, want 3 and 3", primary.calls, fallback.calls)
+	}
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// memoryLimiter 进程内的限流器, 额度不在多个服务实例间共享, 用作 Redis 不可用时的降级方案
+type memoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	windows map[string]*slidingWindow
+	calls   int
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	expireAt time.Time
+}
+
+type slidingWindow struct {
+	hits     []time.Time
+	expireAt time.Time
+}
+
+// 每处理这么多次请求清理一次过期的限流状态
+const memorySweepEvery = 10000
+
+// NewMemoryLimiter 创建进程内的限流器
+func NewMemoryLimiter() Limiter {
+	return &memoryLimiter{
+		buckets: make(map[string]*tokenBucket),
+		windows: make(map[string]*slidingWindow),
+		now:     time.Now,
+	}
+}
+
+func (l *memoryLimiter) Allow(ctx context.Context, key string, rule Rule) (*Result, error) {
+	if rule.Window <= 0 || rule.Limit <= 0 {
+		return nil, fmt.Errorf("ratelimit: invalid rule %+v", rule)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.calls++
+	if l.calls%memorySweepEvery == 0 {
+		l.sweep(now)
+	}
+	if rule.Algorithm == AlgorithmSlidingWindow {
+		return l.allowSlidingWindow(key, rule, now), nil
+	}
+	return l.allowTokenBucket(key, rule, now), nil
+}
+
+func (l *memoryLimiter) allowTokenBucket(key string, rule Rule, now time.Time) *Result {
+	capacity := float64(rule.capacity())
+	rate := float64(rule.Limit) / float64(rule.Window) // 每纳秒补充的令牌数
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+float64(now.Sub(bucket.updated))*rate)
+	bucket.updated = now
+	result := &Result{Limit: rule.capacity(), Window: rule.quotaWindow()}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - bucket.tokens) / rate))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.ResetAfter = time.Duration(math.Ceil((capacity - bucket.tokens) / rate))
+	bucket.expireAt = now.Add(result.ResetAfter)
+
+	return result
+}
+
+func (l *memoryLimiter) allowSlidingWindow(key string, rule Rule, now time.Time) *Result {
+	window, ok := l.windows[key]
+	if !ok {
+		window = &slidingWindow{}
+		l.windows[key] = window
+	}
+	// 移除窗口外的请求记录
+	start := now.Add(-rule.Window)
+	i := 0
+	for i < len(window.hits) && !window.hits[i].After(start) {
+		i++
+	}
+	window.hits = window.hits[i:]
+
+	result := &Result{Limit: rule.Limit, Window: rule.quotaWindow()}
+	if len(window.hits) < rule.Limit {
+		window.hits = append(window.hits, now)
+		result.Allowed = true
+	}
+	result.Remaining = rule.Limit - len(window.hits)
+	result.ResetAfter = window.hits[0].Add(rule.Window).Sub(now)
+	if !result.Allowed {
+		result.RetryAfter = result.ResetAfter
+	}
+	window.expireAt = now.Add(rule.Window)
+
+	return result
+}
+
+func (l *memoryLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.After(bucket.expireAt) {
+			delete(l.buckets, key)
+		}
+	}
+	for key, window := range l.windows {
+		if now.After(window.expireAt) {
+			delete(l.windows, key)
+		}
+	}
+}
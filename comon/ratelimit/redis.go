@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/logger"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// 限流状态保存在 Redis 中, 多个服务实例共享额度, 通过 Lua 脚本保证判断和扣减的原子性
+// 脚本中使用 Redis 的 TIME 作为当前时间, 避免各个服务实例的时钟不一致
+
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', key, math.ceil(capacity / rate) + 1000)
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) / rate)
+end
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+local reset = 0
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+local retry = 0
+if allowed == 0 then
+	retry = reset
+end
+return {allowed, limit - count, retry, reset}
+`)
+
+type redisLimiter struct {
+	client    redis.Scripter
+	keyPrefix string
+}
+
+// NewRedisLimiter 创建基于 Redis 的限流器
+func NewRedisLimiter(client redis.Scripter, keyPrefix string) Limiter {
+	return &redisLimiter{client: client, keyPrefix: keyPrefix}
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, rule Rule) (*Result, error) {
+	key = l.keyPrefix + key
+	windowMs := rule.Window.Milliseconds()
+	if windowMs <= 0 || rule.Limit <= 0 {
+		return nil, fmt.Errorf("ratelimit: invalid rule %+v", rule)
+	}
+	var (
+		values []int64
+		err    error
+	)
+	switch rule.Algorithm {
+	case AlgorithmSlidingWindow:
+		member := fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int63())
+		values, err = slidingWindowScript.Run(ctx, l.client, []string{key}, windowMs, rule.Limit, member).Int64Slice()
+	default:
+		rate := float64(rule.Limit) / float64(windowMs) // 每毫秒补充的令牌数
+		values, err = tokenBucketScript.Run(ctx, l.client, []string{key}, rate, rule.capacity()).Int64Slice()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("ratelimit: unexpected script reply %v", values)
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      rule.capacity(),
+		Window:     rule.quotaWindow(),
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+type fallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	cooldown time.Duration
+	// primary 出错后在这个时间(UnixNano)之前直接使用 fallback
+	skipUntil atomic.Int64
+}
+
+// NewFallbackLimiter 优先使用 primary 限流, primary 出错(比如 Redis 不可用)时降级到 fallback
+// primary 出错后的 cooldown 时间内不再访问 primary, 避免 Redis 不可用时每个请求都要等到超时才降级
+func NewFallbackLimiter(primary, fallback Limiter, cooldown time.Duration) Limiter {
+	return &fallbackLimiter{primary: primary, fallback: fallback, cooldown: cooldown}
+}
+
+func (l *fallbackLimiter) Allow(ctx context.Context, key string, rule Rule) (*Result, error) {
+	if time.Now().UnixNano() < l.skipUntil.Load() {
+		return l.fallback.Allow(ctx, key, rule)
+	}
+	result, err := l.primary.Allow(ctx, key, rule)
+	if err == nil {
+		return result, nil
+	}
+	l.skipUntil.Store(time.Now().Add(l.cooldown).UnixNano())
+	logger.New(ctx).Warn("ratelimit_fallback", "key", key, "cooldown", l.cooldown, "err", err)
+	return l.fallback.Allow(ctx, key, rule)
+}
@@ -0,0 +1,94 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 开放接口的 HMAC-SHA256 请求签名
+// 调用方在请求头中携带 AppKey、时间戳、随机串和签名, 待签名的字符串由下面几部分用换行符连接而成:
+//
+//	HTTP方法(大写)
+//	请求路径
+//	按参数名排序后的查询参数(参数名和值都做URL编码, 同名参数按值排序)
+//	请求体的SHA256摘要(十六进制), 没有请求体时是空串的摘要
+//	时间戳(Unix秒)
+//	随机串
+//
+// 签名 = hex(HMAC-SHA256(AppSecret, 待签名的字符串))
+
+// 签名相关的请求头
+const (
+	HeaderAppKey    = "X-App-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// Payload 参与签名的请求信息
+type Payload struct {
+	Method    string
+	Path      string
+	Query     url.Values
+	BodyHash  string
+	Timestamp int64
+	Nonce     string
+}
+
+// BodyHash 计算请求体的摘要
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// CanonicalQuery 把查询参数按参数名排序后拼接
+func CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// StringToSign 生成待签名的字符串
+func (p *Payload) StringToSign() string {
+	path := p.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		strings.ToUpper(p.Method),
+		path,
+		CanonicalQuery(p.Query),
+		p.BodyHash,
+		strconv.FormatInt(p.Timestamp, 10),
+		p.Nonce,
+	}, "\n")
+}
+
+// Sign 用 AppSecret 计算签名
+func Sign(secret string, p *Payload) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(p.StringToSign()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名, 使用常量时间比较防止时序攻击
+func Verify(secret string, p *Payload, signature string) bool {
+	expected := Sign(secret, p)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
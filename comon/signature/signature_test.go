@@ -0,0 +1,92 @@
+package signature
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBodyHash(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{name: "nil body", body: nil, want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "empty body", body: []byte{}, want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "json body", body: []byte(`{"order_no":"20240101"}`), want: "0f207911e74d2ca74f35ffdbc0e4a1fd85937b3b259bf72453031a76f5c80dfd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BodyHash(tt.body); got != tt.want {
+				t.Errorf("BodyHash() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query url.Values
+		want  string
+	}{
+		{name: "nil query", query: nil, want: ""},
+		{name: "sorted by key", query: url.Values{"b": {"2"}, "a": {"1"}}, want: "a=1&b=2"},
+		{name: "repeated key sorted by value", query: url.Values{"a": {"2", "1"}}, want: "a=1&a=2"},
+		{name: "escaped key and value", query: url.Values{"q k": {"x y&z"}}, want: "q+k=x+y%26z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalQuery(tt.query); got != tt.want {
+				t.Errorf("CanonicalQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	newPayload := func() *Payload {
+		return &Payload{
+			Method:    "post",
+			Path:      "/open/orders",
+			Query:     url.Values{"b": {"x y"}, "a": {"2", "1"}},
+			BodyHash:  BodyHash([]byte(`{"order_no":"20240101"}`)),
+			Timestamp: 1700000000,
+			Nonce:     "abc123",
+		}
+	}
+	// 期望值由独立实现的 HMAC-SHA256 计算得到, 修改签名算法会导致已接入的调用方签名失效
+	const want = "ab2b16007d4558096be00967d7c2b2ff6bfdd5af18942842634222f334a1d4fc"
+	if got := Sign("secret", newPayload()); got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		modify    func(p *Payload)
+		signature string
+		want      bool
+	}{
+		{name: "valid signature", secret: "secret", signature: want, want: true},
+		{name: "upper case signature", secret: "secret", signature: strings.ToUpper(want), want: true},
+		{name: "wrong secret", secret: "other", signature: want},
+		{name: "tampered path", secret: "secret", modify: func(p *Payload) { p.Path = "/open/refunds" }, signature: want},
+		{name: "tampered query", secret: "secret", modify: func(p *Payload) { p.Query.Set("a", "3") }, signature: want},
+		{name: "tampered body", secret: "secret", modify: func(p *Payload) { p.BodyHash = BodyHash(nil) }, signature: want},
+		{name: "replayed with new timestamp", secret: "secret", modify: func(p *Payload) { p.Timestamp++ }, signature: want},
+		{name: "empty signature", secret: "secret", signature: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPayload()
+			if tt.modify != nil {
+				tt.modify(p)
+			}
+			if got := Verify(tt.secret, p, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package httptool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"github/lhh-gh/go-mall/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 按Host熔断: 下游接口故障时不再让每个请求都等到超时
+//   - 关闭: 正常放行, 统计窗口内请求数达到 min_requests 且失败率达到 failure_ratio 时打开
+//   - 打开: 直接返回 CircuitOpenError, 经过 open_timeout 后进入半开
+//   - 半开: 放行 half_open_requests 个探测请求, 全部成功后关闭, 有一个失败就重新打开
+// 连接错误和5xx算失败, 4xx是调用方的问题不算失败
+
+// 熔断器的状态
+const (
+	CircuitClosed   = 0
+	CircuitHalfOpen = 1
+	CircuitOpen     = 2
+)
+
+const (
+	defaultBreakerWindow           = 10 * time.Second
+	defaultBreakerMinRequests      = 20
+	defaultBreakerFailureRatio     = 0.5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 3
+)
+
+// ErrCircuitOpen 熔断器打开时请求直接失败, 可以用 errors.Is(err, httptool.ErrCircuitOpen) 判断
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError 熔断器打开时返回的错误
+type CircuitOpenError struct {
+	Host string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open for host %s", e.Host)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// FallbackFunc 熔断或者请求失败时的降级函数, 返回值作为请求的结果, err 是原本要返回的错误
+type FallbackFunc func(ctx context.Context, method, url string, err error) (httpStatusCode int, respBody []byte, fallbackErr error)
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*circuitBreaker) // Host => 熔断器
+
+	fallbacksMu sync.RWMutex
+	fallbacks   = make(map[string]FallbackFunc) // Host => 降级函数
+)
+
+// RegisterFallback 注册Host的降级函数, 请求被熔断或者失败(连接错误、5xx)时调用
+func RegisterFallback(host string, fallback FallbackFunc) {
+	fallbacksMu.Lock()
+	defer fallbacksMu.Unlock()
+	fallbacks[strings.ToLower(host)] = fallback
+}
+
+func getFallback(host string) (FallbackFunc, bool) {
+	fallbacksMu.RLock()
+	defer fallbacksMu.RUnlock()
+	fallback, ok := fallbacks[strings.ToLower(host)]
+	return fallback, ok
+}
+
+// CircuitState 查询Host当前的熔断状态
+func CircuitState(host string) int {
+	breaker := getBreaker(host)
+	if breaker == nil {
+		return CircuitClosed
+	}
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	return breaker.currentState(time.Now())
+}
+
+// getBreaker 获取Host的熔断器, Host关闭了熔断时返回 nil
+func getBreaker(host string) *circuitBreaker {
+	host = strings.ToLower(host)
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	if breaker, ok := breakers[host]; ok {
+		return breaker
+	}
+	policy, enabled := breakerPolicy(host)
+	var breaker *circuitBreaker
+	if enabled {
+		breaker = &circuitBreaker{host: host, policy: policy, windowStart: time.Now()}
+	}
+	breakers[host] = breaker
+	return breaker
+}
+
+// breakerPolicy 合并默认的和Host单独设置的熔断策略
+func breakerPolicy(host string) (policy config.BreakerPolicy, enabled bool) {
+	policy = config.BreakerPolicy{
+		Window:           defaultBreakerWindow,
+		MinRequests:      defaultBreakerMinRequests,
+		FailureRatio:     defaultBreakerFailureRatio,
+		OpenTimeout:      defaultBreakerOpenTimeout,
+		HalfOpenRequests: defaultBreakerHalfOpenRequests,
+	}
+	if config.Breaker == nil {
+		return policy, true
+	}
+	mergeBreakerPolicy(&policy, config.Breaker.Default)
+	for _, hostPolicy := range config.Breaker.Hosts {
+		if strings.EqualFold(hostPolicy.Host, host) {
+			mergeBreakerPolicy(&policy, hostPolicy)
+		}
+	}
+	return policy, !policy.Disabled
+}
+
+func mergeBreakerPolicy(dst *config.BreakerPolicy, src config.BreakerPolicy) {
+	if src.Disabled {
+		dst.Disabled = true
+	}
+	if src.Window > 0 {
+		dst.Window = src.Window
+	}
+	if src.MinRequests > 0 {
+		dst.MinRequests = src.MinRequests
+	}
+	if src.FailureRatio > 0 {
+		dst.FailureRatio = src.FailureRatio
+	}
+	if src.OpenTimeout > 0 {
+		dst.OpenTimeout = src.OpenTimeout
+	}
+	if src.HalfOpenRequests > 0 {
+		dst.HalfOpenRequests = src.HalfOpenRequests
+	}
+}
+
+type circuitBreaker struct {
+	mu     sync.Mutex
+	host   string
+	policy config.BreakerPolicy
+
+	state       int
+	windowStart time.Time // 关闭状态下当前统计窗口的开始时间
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // 半开状态已经放行的探测请求数
+	probeOks    int // 半开状态成功的探测请求数
+}
+
+// allow 判断是否放行请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.currentState(time.Now()) {
+	case CircuitOpen:
+		metrics.HTTPClientCircuitRejections.WithLabelValues(b.host).Inc()
+		return false
+	case CircuitHalfOpen:
+		if b.probes >= b.policy.HalfOpenRequests {
+			metrics.HTTPClientCircuitRejections.WithLabelValues(b.host).Inc()
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// record 记录请求的结果
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	switch b.currentState(now) {
+	case CircuitClosed:
+		if now.Sub(b.windowStart) >= b.policy.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.policy.MinRequests && float64(b.failures)/float64(b.requests) >= b.policy.FailureRatio {
+			b.setState(CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			b.setState(CircuitOpen, now)
+			return
+		}
+		b.probeOks++
+		if b.probeOks >= b.policy.HalfOpenRequests {
+			b.setState(CircuitClosed, now)
+		}
+	}
+}
+
+// abort 请求被调用方取消, 不计入结果, 归还半开状态占用的探测名额
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.currentState(time.Now()) == CircuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// currentState 打开状态超过 open_timeout 后转为半开, 调用方需要持有锁
+func (b *circuitBreaker) currentState(now time.Time) int {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.policy.OpenTimeout {
+		b.setState(CircuitHalfOpen, now)
+	}
+	return b.state
+}
+
+func (b *circuitBreaker) setState(state int, now time.Time) {
+	if b.state != state {
+		logger.New(context.Background()).Warn("HTTP_CIRCUIT_STATE_CHANGE", "host", b.host, "from", b.state, "to", state, "requests", b.requests, "failures", b.failures)
+	}
+	b.state = state
+	switch state {
+	case CircuitOpen:
+		b.openedAt = now
+	case CircuitHalfOpen:
+		b.probes, b.probeOks = 0, 0
+	case CircuitClosed:
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	metrics.HTTPClientCircuitState.WithLabelValues(b.host).Set(float64(state))
+}
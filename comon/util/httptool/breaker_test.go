@@ -0,0 +1,140 @@
+package httptool
+
+import (
+	"github/lhh-gh/go-mall/config"
+	"testing"
+	"time"
+)
+
+func newTestBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		host: "breaker.test",
+		policy: config.BreakerPolicy{
+			Window:           10 * time.Second,
+			MinRequests:      4,
+			FailureRatio:     0.5,
+			OpenTimeout:      30 * time.Second,
+			HalfOpenRequests: 2,
+		},
+		windowStart: time.Now(),
+	}
+}
+
+// expireOpenTimeout 把打开的时间往前推, 模拟已经过了 open_timeout
+func expireOpenTimeout(b *circuitBreaker) {
+	b.openedAt = b.openedAt.Add(-b.policy.OpenTimeout)
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	type step struct {
+		action    string // allow、ok、fail、abort、expire
+		wantAllow bool   // action 为 allow 时期望的结果
+		wantState int    // 执行后期望的状态
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed below min requests",
+			steps: []step{
+				{action: "fail", wantState: CircuitClosed},
+				{action: "fail", wantState: CircuitClosed},
+				{action: "fail", wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "stays closed below failure ratio",
+			steps: []step{
+				{action: "ok", wantState: CircuitClosed},
+				{action: "ok", wantState: CircuitClosed},
+				{action: "ok", wantState: CircuitClosed},
+				{action: "fail", wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "opens at failure ratio and rejects",
+			steps: []step{
+				{action: "ok", wantState: CircuitClosed},
+				{action: "ok", wantState: CircuitClosed},
+				{action: "fail", wantState: CircuitClosed},
+				{action: "fail", wantState: CircuitOpen},
+				{action: "allow", wantAllow: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "half open closes after all probes succeed",
+			steps: []step{
+				{action: "fail"}, {action: "fail"}, {action: "fail"}, {action: "fail", wantState: CircuitOpen},
+				{action: "expire", wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: true, wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: true, wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: false, wantState: CircuitHalfOpen},
+				{action: "ok", wantState: CircuitHalfOpen},
+				{action: "ok", wantState: CircuitClosed},
+				{action: "allow", wantAllow: true, wantState: CircuitClosed},
+			},
+		},
+		{
+			name: "half open reopens on probe failure",
+			steps: []step{
+				{action: "fail"}, {action: "fail"}, {action: "fail"}, {action: "fail", wantState: CircuitOpen},
+				{action: "expire", wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: true, wantState: CircuitHalfOpen},
+				{action: "fail", wantState: CircuitOpen},
+				{action: "allow", wantAllow: false, wantState: CircuitOpen},
+			},
+		},
+		{
+			name: "aborted probe returns its slot",
+			steps: []step{
+				{action: "fail"}, {action: "fail"}, {action: "fail"}, {action: "fail", wantState: CircuitOpen},
+				{action: "expire", wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: true, wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: true, wantState: CircuitHalfOpen},
+				{action: "abort", wantState: CircuitHalfOpen},
+				{action: "allow", wantAllow: true, wantState: CircuitHalfOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker()
+			for i, s := range tt.steps {
+				switch s.action {
+				case "allow":
+					if got := b.allow(); got != s.wantAllow {
+						t.Fatalf("step %d: allow() = %v, want %v", i, got, s.wantAllow)
+					}
+				case "ok":
+					b.record(false)
+				case "fail":
+					b.record(true)
+				case "abort":
+					b.abort()
+				case "expire":
+					expireOpenTimeout(b)
+				}
+				b.mu.Lock()
+				state := b.currentState(time.Now())
+				b.mu.Unlock()
+				if state != s.wantState {
+					t.Fatalf("step %d (%s): state = %d, want %d", i, s.action, state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerWindowReset(t *testing.T) {
+	b := newTestBreaker()
+	b.record(true)
+	b.record(true)
+	b.record(true)
+	// 统计窗口过期后重新计数, 之前的失败不再计入
+	b.windowStart = b.windowStart.Add(-b.policy.Window)
+	b.record(true)
+	if b.state != CircuitClosed || b.requests != 1 || b.failures != 1 {
+		t.Fatalf("state = %d, requests = %d, failures = %d, want closed with a fresh window", b.state, b.requests, b.failures)
+	}
+}
@@ -4,45 +4,66 @@ import (
 	"bytes"
 	"context"
 	"errors"
-	"fmt"
 	"github/lhh-gh/go-mall/comon/errcode"
 	"github/lhh-gh/go-mall/comon/logger"
-	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/config"
 
-	"io/ioutil"
-	"net"
+	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
-var _Client *http.Client
+var (
+	clientMu sync.RWMutex
+	_Client  *http.Client
+)
 
 func getHttpClient() *http.Client {
-	if _Client != nil {
-		return _Client
+	clientMu.RLock()
+	client := _Client
+	clientMu.RUnlock()
+	if client != nil {
+		return client
 	}
 
-	tr := &http.Transport{
-		//Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		MaxIdleConns:          100,              // 最大空闲连接, 0 表示不限制
-		IdleConnTimeout:       90 * time.Second, // 空闲连接在连接池中的最大存活时间, 0表示不限制
-		MaxIdleConnsPerHost:   50,               //每个Host (host + port) 的最大空闲连接, 不设置默认用 DefaultMaxIdleConnsPerHost
-		MaxConnsPerHost:       50,               // 每个Host 的最大连接, 0 表示不限制 与 MaxIdleConnsPerHost 相等(尽量使用空闲连接)
-		ForceAttemptHTTP2:     true,
-		TLSHandshakeTimeout:   10 * time.Second, // waiting time for TLS handshake, zero means no timeout
-		ExpectContinueTimeout: 1 * time.Second,
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if _Client != nil {
+		return _Client
 	}
 
+	// 默认的 Client 使用默认的连接池配置, 不会出错
+	tr, _ := newTransport(&config.UpstreamConfig{})
 	_Client = &http.Client{Transport: tr}
 	return _Client
 }
 
+// SetTransport 替换默认 Client 的 RoundTripper, 返回恢复原来的 Client 的函数
+// 用于测试中注入 ReplayTransport 这类不发起真实请求的 RoundTripper
+func SetTransport(rt http.RoundTripper) (restore func()) {
+	old := getHttpClient()
+	clientMu.Lock()
+	_Client = &http.Client{Transport: rt}
+	clientMu.Unlock()
+	return func() {
+		clientMu.Lock()
+		_Client = old
+		clientMu.Unlock()
+	}
+}
+
+// Request 发起请求, 返回响应的状态码和响应体, 响应状态码不是 2xx 时返回 *HTTPError, 响应体为出错时下游返回的内容
 func Request(method string, url string, options ...Option) (httpStatusCode int, respBody []byte, err error) {
-	start := time.Now()
+	resp, err := Do(method, url, options...)
+	if resp != nil {
+		httpStatusCode, respBody = resp.StatusCode, resp.Body
+	}
+	return
+}
+
+// Do 发起请求, 和 Request 相同, 需要读取响应头时使用
+func Do(method string, url string, options ...Option) (resp *Response, err error) {
 	reqOpts := defaultRequestOptions() // 默认的请求选项
 	for _, opt := range options {      // 在reqOpts上应用通过options设置的选项
 		err = opt.apply(reqOpts)
@@ -50,58 +71,199 @@ func Request(method string, url string, options ...Option) (httpStatusCode int,
 			return
 		}
 	}
+	if reqOpts.upstream != nil {
+		reqOpts.upstream.applyTo(reqOpts)
+	}
 	log := logger.New(reqOpts.ctx)
 	defer func() {
 		if err != nil {
-			log.Error("HTTP_REQUEST_ERROR_LOG", "method", method, "url", url, "body", reqOpts.data, "reply", respBody, "err", err)
+			var respBody []byte
+			if resp != nil {
+				respBody = resp.Body
+			}
+			log.Error("HTTP_REQUEST_ERROR_LOG", "method", method, "url", url, "body", reqOpts.logBody(), "reply", reqOpts.logReply(respBody), "err", err)
+		}
+	}()
+	// 请求被熔断或者失败时, 有降级函数的用降级函数的结果代替
+	var last attemptResult
+	defer func() {
+		if !last.failed {
+			return
+		}
+		if fallback, ok := getFallback(last.host); ok {
+			log.Warn("HTTP_REQUEST_FALLBACK_LOG", "method", method, "url", url, "httpStatusCode", last.httpStatusCode, "err", err)
+			httpStatusCode, respBody, fallbackErr := fallback(reqOpts.ctx, method, url, err)
+			resp, err = &Response{StatusCode: httpStatusCode, Header: http.Header{}, Body: respBody}, fallbackErr
 		}
 	}()
+
+	maxAttempts := 1
+	if reqOpts.retry != nil && reqOpts.retry.allowMethod(method) {
+		maxAttempts = reqOpts.retry.MaxAttempts
+	}
+	if reqOpts.body != nil && !reqOpts.body.rewindable {
+		// 请求体只能读取一次
+		maxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		last = doRequest(method, url, reqOpts, attempt)
+		resp, err = last.response(), last.err
+		if attempt >= maxAttempts || !last.retryable {
+			return
+		}
+		wait := reqOpts.retry.backoff(attempt, last.retryAfter)
+		if deadline, ok := reqOpts.ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// 等不到下一次请求上游就超时了, 直接返回这次的结果
+			return
+		}
+		log.Warn("HTTP_REQUEST_RETRY_LOG", "method", method, "url", url, "attempt", attempt, "httpStatusCode", last.httpStatusCode, "err", err, "wait/ms", wait.Milliseconds())
+		timer := time.NewTimer(wait)
+		select {
+		case <-reqOpts.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// attemptResult 单次请求的结果
+type attemptResult struct {
+	httpStatusCode int
+	header         http.Header
+	respBody       []byte
+	err            error
+	retryable      bool          // 是否可以重试: 连接错误、5xx 和 429
+	retryAfter     time.Duration // 响应头 Retry-After 要求的等待时间
+	host           string
+	failed         bool // 是否被熔断或者失败(连接错误、5xx), 失败时调用Host的降级函数
+}
+
+// doRequest 发起一次请求, 每次请求单独计算超时时间, 重试时重新生成请求体和签名
+func doRequest(method string, url string, reqOpts *requestOption, attempt int) (result attemptResult) {
+	start := time.Now()
+	log := logger.New(reqOpts.ctx)
 	// 创建请求对象
-	req, err := http.NewRequest(method, url, bytes.NewReader(reqOpts.data))
+	body, err := reqOpts.requestBody()
 	if err != nil {
+		result.err = err
+		return
+	}
+	if reqOpts.upstream != nil {
+		url = reqOpts.upstream.URL(url)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+		result.err = err
 		return
 	}
-	reqOpts.ctx, _ = context.WithTimeout(reqOpts.ctx, reqOpts.timeout) // 给 Request 设置Timeout
-	req = req.WithContext(reqOpts.ctx)
 	defer req.Body.Close()
+	if reqOpts.body != nil {
+		req.ContentLength = reqOpts.body.size
+	}
+	result.host = req.URL.Host
+	// 熔断器打开时不发起请求
+	breaker := getBreaker(req.URL.Host)
+	if breaker != nil && !breaker.allow() {
+		result.err = &CircuitOpenError{Host: req.URL.Host}
+		result.failed = true
+		return
+	}
+	// 给 Request 设置Timeout, 上游 ctx 的截止时间更早时以上游为准
+	ctx, cancel := context.WithTimeout(reqOpts.ctx, reqOpts.timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
 
-	// 在Header中添加追踪信息 把内部服务串起来
-	traceId, spanId, _ := util.GetTraceInfoFromCtx(reqOpts.ctx)
-	reqOpts.headers["traceid"] = traceId
-	reqOpts.headers["spanid"] = spanId
 	if len(reqOpts.headers) != 0 { // 设置请求头
 		for key, value := range reqOpts.headers {
 			req.Header.Add(key, value)
 		}
 	}
-	// 发起请求
-	client := getHttpClient()
-	resp, err := client.Do(req)
+	if reqOpts.download != nil { // 续传
+		if rangeHeader := reqOpts.download.rangeHeader(); rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+	}
+	// 经过拦截器链发起请求, 追踪信息、签名等请求头由拦截器设置
+	client := reqOpts.client
+	if client == nil {
+		client = getHttpClient()
+	}
+	call := runInterceptors(client, req, attempt, reqOpts.interceptors)
+	resp, err := call.Response, call.Err
 	if err != nil {
+		result.err = err
+		// 上游的 ctx 已经结束或者请求被拦截器中止时不再重试, 也不算下游的失败
+		result.retryable = call.sent && reqOpts.ctx.Err() == nil
+		result.failed = result.retryable
+		if breaker != nil {
+			if result.failed {
+				breaker.record(true)
+			} else {
+				breaker.abort()
+			}
+		}
 		return
 	}
+	result.failed = resp.StatusCode >= http.StatusInternalServerError
+	if breaker != nil {
+		if call.sent {
+			breaker.record(result.failed)
+		} else {
+			// 拦截器返回的缓存响应
+			breaker.abort()
+		}
+	}
 	defer resp.Body.Close()
 	// 记录请求日志
-	dur := time.Since(start).Milliseconds()
 	defer func() {
+		dur := time.Since(start).Milliseconds()
 		if dur >= 3000 { // 超过 3s 返回, 记一条 Warn 日志
-			log.Warn("HTTP_REQUEST_SLOW_LOG", "method", method, "url", url, "body", reqOpts.data, "reply", respBody, "err", err, "dur/ms", dur)
+			log.Warn("HTTP_REQUEST_SLOW_LOG", "method", method, "url", url, "attempt", attempt, "body", reqOpts.logBody(), "reply", reqOpts.logReply(result.respBody), "err", result.err, "dur/ms", dur)
 		} else {
-			log.Debug("HTTP_REQUEST_DEBUG_LOG", "method", method, "url", url, "body", string(reqOpts.data), "reply", string(respBody), "err", err, "dur/ms", dur)
+			log.Debug("HTTP_REQUEST_DEBUG_LOG", "method", method, "url", url, "attempt", attempt, "body", reqOpts.logBody(), "reply", reqOpts.logReply(result.respBody), "err", result.err, "dur/ms", dur)
 		}
 	}()
 
-	httpStatusCode = resp.StatusCode
-	if httpStatusCode != http.StatusOK {
-		// 返回非 200 时Go的 http 库不回返回error, 这里处理成error 调用方好判断
-		err = errcode.Wrap("request api error", errors.New(fmt.Sprintf("non 200 response, response code: %d", httpStatusCode)))
+	result.httpStatusCode = resp.StatusCode
+	result.header = resp.Header
+	if result.header == nil {
+		result.header = http.Header{}
+	}
+	if !isSuccessStatus(result.httpStatusCode) {
+		// 返回非 2xx 时Go的 http 库不回返回error, 这里处理成error 调用方好判断
+		// 下游返回的错误信息保留在 HTTPError 中, 最多读取 maxErrorBodySize 个字节
+		result.respBody, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		result.err = errcode.Wrap("request api error", &HTTPError{StatusCode: result.httpStatusCode, Header: resp.Header, Body: result.respBody})
+		result.retryable = result.httpStatusCode >= http.StatusInternalServerError || result.httpStatusCode == http.StatusTooManyRequests
+		result.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		// 读完响应体, 连接才能被复用
+		io.Copy(io.Discard, resp.Body)
 		return
 	}
 
-	respBody, _ = ioutil.ReadAll(resp.Body)
+	if reqOpts.download != nil {
+		if result.err = reqOpts.download.receive(resp, reqOpts.progress); result.err != nil {
+			// 传输中断时可以重试, 重试时从中断的位置续传
+			result.retryable = reqOpts.download.writeErr == nil && !errors.Is(result.err, ErrRangeNotSupported) && reqOpts.ctx.Err() == nil
+		}
+		return
+	}
+	result.respBody, result.err = io.ReadAll(resp.Body)
 	return
 }
 
+func (result attemptResult) response() *Response {
+	if result.header == nil {
+		// 没有拿到响应
+		return nil
+	}
+	return &Response{StatusCode: result.httpStatusCode, Header: result.header, Body: result.respBody}
+}
+
 // Get 发起GET请求
 func Get(ctx context.Context, url string, options ...Option) (httpStatusCode int, respBody []byte, err error) {
 	options = append(options, WithContext(ctx))
@@ -122,10 +284,19 @@ func Post(ctx context.Context, url string, data []byte, options ...Option) (http
 
 // 针对可选的HTTP请求配置项，模仿gRPC使用的Options设计模式实现
 type requestOption struct {
-	ctx     context.Context
-	timeout time.Duration
-	data    []byte
-	headers map[string]string
+	ctx          context.Context
+	timeout      time.Duration
+	data         []byte
+	headers      map[string]string
+	retry        *RetryPolicy
+	body         *streamBody // 流式请求体, 设置后忽略 data
+	download     *downloader // 把响应体写入 io.Writer
+	progress     ProgressFunc
+	interceptors []Interceptor // 只对这个请求生效的拦截器
+	client       *http.Client  // 为空时使用默认的 Client
+	upstream     *Upstream
+	// 是否通过 WithTimeout 设置了超时时间, 没有设置时使用下游服务配置的超时时间
+	timeoutSet bool
 }
 
 type Option interface {
@@ -147,6 +318,37 @@ func defaultRequestOptions() *requestOption {
 	}
 }
 
+// requestBody 生成这次请求的请求体
+func (opts *requestOption) requestBody() (io.Reader, error) {
+	if opts.body == nil {
+		return bytes.NewReader(opts.data), nil
+	}
+	body, err := opts.body.open()
+	if err != nil {
+		return nil, err
+	}
+	if opts.progress != nil {
+		body = &progressReader{ReadCloser: body, total: opts.body.size, progress: opts.progress}
+	}
+	return body, nil
+}
+
+// logBody 日志中记录的请求体, 流式请求体不记录
+func (opts *requestOption) logBody() string {
+	if opts.body != nil {
+		return streamingBodyLog
+	}
+	return string(opts.data)
+}
+
+// logReply 日志中记录的响应体, 下载的响应体不记录
+func (opts *requestOption) logReply(respBody []byte) string {
+	if opts.download != nil {
+		return streamingBodyLog
+	}
+	return string(respBody)
+}
+
 func WithContext(ctx context.Context) Option {
 	return optionFunc(func(opts *requestOption) (err error) {
 		opts.ctx = ctx
@@ -156,7 +358,7 @@ func WithContext(ctx context.Context) Option {
 
 func WithTimeout(timeout time.Duration) Option {
 	return optionFunc(func(opts *requestOption) (err error) {
-		opts.timeout, err = timeout, nil
+		opts.timeout, opts.timeoutSet = timeout, true
 		return
 	})
 }
@@ -176,3 +378,19 @@ func WithData(data []byte) Option {
 		return
 	})
 }
+
+// WithClient 使用指定的 Client 发起请求, client 为空时使用默认的 Client
+func WithClient(client *http.Client) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.client = client
+		return
+	})
+}
+
+// WithSignature 按开放接口的签名规则(见 signature 包)给请求签名, 用于调用合作方或者其他服务的开放接口
+func WithSignature(appKey, appSecret string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.interceptors = append(opts.interceptors, SignatureInterceptor(appKey, appSecret))
+		return
+	})
+}
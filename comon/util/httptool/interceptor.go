@@ -0,0 +1,402 @@
+package httptool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"github/lhh-gh/go-mall/comon/util"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 发出请求的拦截器, 用法和 gin 的中间件一样:
+// 拦截器按顺序执行, 在 c.Next() 之前处理请求, 之后处理响应, 调用 c.Abort() 后不再执行后面的拦截器和发送请求
+// 全局拦截器(Use 添加)先于请求中 WithInterceptors 设置的拦截器执行, 每次重试都会重新执行整个拦截器链
+//
+//	httptool.Use(httptool.LoggingInterceptor())
+//	httptool.Get(ctx, url, httptool.WithInterceptors(httptool.ForwardAuthInterceptor()))
+
+// Interceptor 拦截器
+type Interceptor func(c *Call)
+
+// Call 请求在拦截器链中的上下文
+type Call struct {
+	Request  *http.Request
+	Response *http.Response // 执行完 c.Next() 后为请求的响应, 没有拿到响应时为 nil
+	Err      error
+	Attempt  int // 第几次请求, 从 1 开始
+
+	handlers []Interceptor
+	index    int
+	sent     bool // 请求是否发送给了下游, 被拦截器中止的请求不重试也不计入熔断
+}
+
+const abortIndex = math.MaxInt / 2
+
+// Next 执行后面的拦截器, 最后一个拦截器之后发送请求
+func (c *Call) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort 不再执行后面的拦截器, 也不发送请求, 需要由拦截器设置 Response 或者 Err
+func (c *Call) Abort() {
+	c.index = abortIndex
+}
+
+// AbortWithError 以错误结束请求
+func (c *Call) AbortWithError(err error) {
+	c.Err = err
+	c.Abort()
+}
+
+func (c *Call) Context() context.Context {
+	return c.Request.Context()
+}
+
+var (
+	interceptorsMu     sync.RWMutex
+	globalInterceptors = []Interceptor{TraceInterceptor(), MetricsInterceptor()}
+)
+
+// Use 添加全局拦截器, 对所有请求生效, 需要在服务启动时调用
+func Use(interceptors ...Interceptor) {
+	interceptorsMu.Lock()
+	defer interceptorsMu.Unlock()
+	globalInterceptors = append(globalInterceptors, interceptors...)
+}
+
+// WithInterceptors 添加只对这个请求生效的拦截器
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.interceptors = append(opts.interceptors, interceptors...)
+		return
+	})
+}
+
+// runInterceptors 执行拦截器链, 链的最后用 client 发送请求
+func runInterceptors(client *http.Client, req *http.Request, attempt int, interceptors []Interceptor) *Call {
+	interceptorsMu.RLock()
+	handlers := make([]Interceptor, 0, len(globalInterceptors)+len(interceptors)+1)
+	handlers = append(handlers, globalInterceptors...)
+	interceptorsMu.RUnlock()
+	handlers = append(handlers, interceptors...)
+	handlers = append(handlers, func(c *Call) {
+		c.sent = true
+		c.Response, c.Err = client.Do(c.Request)
+	})
+	c := &Call{Request: req, Attempt: attempt, handlers: handlers, index: -1}
+	c.Next()
+	if c.Response == nil && c.Err == nil {
+		c.Err = errors.New("request aborted by interceptor without response")
+	}
+	return c
+}
+
+// TraceInterceptor 在请求头中添加追踪信息, 把内部服务串起来, 默认启用
+func TraceInterceptor() Interceptor {
+	return func(c *Call) {
+		traceId, spanId, _ := util.GetTraceInfoFromCtx(c.Context())
+		c.Request.Header.Set("traceid", traceId)
+		c.Request.Header.Set("spanid", spanId)
+	}
+}
+
+// MetricsInterceptor 按Host记录调用耗时, 请求没有拿到响应时 status 记为 error, 默认启用
+// 后面的拦截器可能中止请求又没有设置响应, 这时 Err 和 Response 都为 nil
+func MetricsInterceptor() Interceptor {
+	return func(c *Call) {
+		start := time.Now()
+		c.Next()
+		status := "error"
+		if c.Err == nil && c.Response != nil {
+			status = strconv.Itoa(c.Response.StatusCode)
+		}
+		metrics.HTTPClientRequestDuration.WithLabelValues(c.Request.URL.Host, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// AuthInterceptor 用 tokenSource 获取Token, 放到请求头 Authorization 中, 请求中已经设置了 Authorization 时不覆盖
+// tokenSource 返回空的Token时不设置
+func AuthInterceptor(tokenSource func(ctx context.Context) (string, error)) Interceptor {
+	return func(c *Call) {
+		if c.Request.Header.Get("Authorization") != "" {
+			return
+		}
+		token, err := tokenSource(c.Context())
+		if err != nil {
+			c.AbortWithError(err)
+			return
+		}
+		if token != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// ForwardAuthInterceptor 透传当前用户的Token, 用于调用项目内的其他服务
+func ForwardAuthInterceptor() Interceptor {
+	return AuthInterceptor(func(ctx context.Context) (string, error) {
+		if identity, ok := auth.GetIdentity(ctx); ok {
+			return identity.AccessToken, nil
+		}
+		return "", nil
+	})
+}
+
+// SignatureInterceptor 按开放接口的签名规则(见 signature 包)给请求签名, 不支持流式请求体
+func SignatureInterceptor(appKey, appSecret string) Interceptor {
+	signer := &requestSigner{appKey: appKey, appSecret: appSecret}
+	return func(c *Call) {
+		var body []byte
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			if c.Request.GetBody == nil {
+				c.AbortWithError(errors.New("request signature does not support streaming body"))
+				return
+			}
+			reader, err := c.Request.GetBody()
+			if err != nil {
+				c.AbortWithError(err)
+				return
+			}
+			body, err = io.ReadAll(reader)
+			if err != nil {
+				c.AbortWithError(err)
+				return
+			}
+		}
+		signer.sign(c.Request, body)
+	}
+}
+
+// 日志中脱敏的查询参数
+var defaultRedactQuery = []string{"token", "access_token", "password", "secret", "sign", "signature"}
+
+// LoggingInterceptor 记录请求和响应的请求头, 认证相关的请求头和查询参数会被脱敏
+// redactHeaders 是除了默认的认证请求头之外还需要脱敏的头
+func LoggingInterceptor(redactHeaders ...string) Interceptor {
+	redactHeaders = append(append([]string{}, defaultRedactHeaders...), redactHeaders...)
+	return func(c *Call) {
+		start := time.Now()
+		c.Next()
+		kv := []interface{}{
+			"method", c.Request.Method,
+			"url", redactURL(c.Request),
+			"attempt", c.Attempt,
+			"header", redactHeader(c.Request.Header, redactHeaders),
+			"dur/ms", time.Since(start).Milliseconds(),
+		}
+		switch {
+		case c.Err != nil:
+			kv = append(kv, "err", c.Err)
+		case c.Response != nil:
+			kv = append(kv, "status", c.Response.StatusCode, "reply_header", redactHeader(c.Response.Header, redactHeaders))
+		}
+		logger.New(c.Context()).Info("HTTP_REQUEST_ACCESS_LOG", kv...)
+	}
+}
+
+func redactHeader(header http.Header, names []string) http.Header {
+	redacted := header.Clone()
+	for _, name := range names {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, redactedValue)
+		}
+	}
+	return redacted
+}
+
+func redactURL(req *http.Request) string {
+	if req.URL.RawQuery == "" {
+		return req.URL.String()
+	}
+	redacted := *req.URL
+	query := redacted.Query()
+	for key := range query {
+		for _, name := range defaultRedactQuery {
+			if strings.EqualFold(key, name) {
+				query.Set(key, redactedValue)
+			}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+// CacheInterceptor 在进程内缓存GET请求 200 的响应, 缓存时间优先使用响应头 Cache-Control 的 max-age, 没有时使用 ttl
+// 响应头 Cache-Control 为 no-store、no-cache 或者 private 时不缓存, 缓存的是完整的响应体, 不要用于下载大文件
+// 缓存在所有请求间共享: 携带 Authorization 或 Cookie 的请求, 只缓存和使用 Cache-Control 中有 public 的响应;
+// 响应头有 Vary 时, Vary 中的请求头和缓存时的请求一致才使用缓存, Vary: * 不缓存
+func CacheInterceptor(ttl time.Duration, maxEntries int) Interceptor {
+	cache := &responseCache{entries: make(map[string]*cachedResponse), maxEntries: maxEntries}
+	return func(c *Call) {
+		if c.Request.Method != http.MethodGet || c.Request.Header.Get("Range") != "" {
+			return
+		}
+		key := c.Request.URL.String()
+		if cached, ok := cache.get(key); ok && cached.match(c.Request) {
+			c.Response = cached.response(c.Request)
+			c.Abort()
+			return
+		}
+		c.Next()
+		if c.Err != nil || c.Response == nil || c.Response.StatusCode != http.StatusOK {
+			return
+		}
+		cacheControl := c.Response.Header.Get("Cache-Control")
+		cacheTTL, cacheable := responseCacheTTL(cacheControl, ttl)
+		if !cacheable {
+			return
+		}
+		public := hasCacheDirective(cacheControl, "public")
+		if withCredentials(c.Request) && !public {
+			return
+		}
+		vary, cacheable := responseVary(c.Response.Header, c.Request.Header)
+		if !cacheable {
+			return
+		}
+		body, err := io.ReadAll(c.Response.Body)
+		c.Response.Body.Close()
+		if err != nil {
+			c.Response, c.Err = nil, err
+			return
+		}
+		c.Response.Body = io.NopCloser(bytes.NewReader(body))
+		cache.set(key, &cachedResponse{
+			statusCode: c.Response.StatusCode,
+			header:     c.Response.Header.Clone(),
+			body:       body,
+			vary:       vary,
+			public:     public,
+			expiresAt:  time.Now().Add(cacheTTL),
+		})
+	}
+}
+
+func withCredentials(req *http.Request) bool {
+	return req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != ""
+}
+
+func hasCacheDirective(cacheControl, name string) bool {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseVary 取出响应头 Vary 中的请求头在这次请求中的值, Vary: * 时不能缓存
+func responseVary(respHeader, reqHeader http.Header) (map[string]string, bool) {
+	vary := make(map[string]string)
+	for _, value := range respHeader.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				vary[http.CanonicalHeaderKey(name)] = strings.Join(reqHeader.Values(name), ",")
+			}
+		}
+	}
+	return vary, true
+}
+
+func responseCacheTTL(cacheControl string, ttl time.Duration) (time.Duration, bool) {
+	for _, directive := range strings.Split(strings.ToLower(cacheControl), ",") {
+		directive = strings.TrimSpace(directive)
+		switch {
+		case directive == "no-store" || directive == "no-cache" || directive == "private":
+			return 0, false
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl, ttl > 0
+}
+
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	vary       map[string]string // Vary 中的请求头 => 缓存时请求中的值
+	public     bool
+	expiresAt  time.Time
+}
+
+// match 判断缓存的响应能否用于这次请求
+func (r *cachedResponse) match(req *http.Request) bool {
+	if withCredentials(req) && !r.public {
+		return false
+	}
+	for name, value := range r.vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(r.statusCode) + " " + http.StatusText(r.statusCode),
+		StatusCode:    r.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+// responseCache 超过容量时先清理过期的响应, 仍然超过时清空
+type responseCache struct {
+	mu         sync.RWMutex
+	entries    map[string]*cachedResponse
+	maxEntries int
+}
+
+func (cache *responseCache) get(key string) (*cachedResponse, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	cached, ok := cache.entries[key]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return nil, false
+	}
+	return cached, true
+}
+
+func (cache *responseCache) set(key string, cached *cachedResponse) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.maxEntries > 0 && len(cache.entries) >= cache.maxEntries {
+		now := time.Now()
+		for entryKey, entry := range cache.entries {
+			if now.After(entry.expiresAt) {
+				delete(cache.entries, entryKey)
+			}
+		}
+		if len(cache.entries) >= cache.maxEntries {
+			cache.entries = make(map[string]*cachedResponse)
+		}
+	}
+	cache.entries[key] = cached
+}
@@ -0,0 +1,106 @@
+package httptool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// 请求JSON接口的泛型方法, 省去每个Lib里重复的编码请求、发起请求和解析响应
+// 下游返回非 2xx 时返回的错误中包含 *HTTPError, 可以用 DecodeError 把错误响应体解析成下游定义的错误结构
+// 返回的 *Response 中有状态码和响应头, 没有拿到响应时为 nil
+
+// GetJSON 发起GET请求并把响应体解析成 T
+func GetJSON[T any](ctx context.Context, url string, options ...Option) (*T, *Response, error) {
+	options = append(options, WithContext(ctx))
+	resp, err := Do("GET", url, options...)
+	if err != nil {
+		return nil, resp, err
+	}
+	return decodeJSON[T](resp)
+}
+
+// PostJSON 把 req 编码成JSON发起POST请求, 并把响应体解析成 Resp
+func PostJSON[Req, Resp any](ctx context.Context, url string, req Req, options ...Option) (*Resp, *Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode request body error: %w", err)
+	}
+	newOptions := []Option{WithHeaders(map[string]string{"Content-Type": "application/json"}), WithData(data), WithContext(ctx)}
+	newOptions = append(newOptions, options...)
+	resp, err := Do("POST", url, newOptions...)
+	if err != nil {
+		return nil, resp, err
+	}
+	return decodeJSON[Resp](resp)
+}
+
+func decodeJSON[T any](resp *Response) (*T, *Response, error) {
+	result := new(T)
+	if len(resp.Body) == 0 {
+		// 204 这类没有响应体的响应
+		return result, resp, nil
+	}
+	if err := json.Unmarshal(resp.Body, result); err != nil {
+		return nil, resp, fmt.Errorf("decode response body error: %w", err)
+	}
+	return result, resp, nil
+}
+
+// Envelope 项目内服务统一的响应结构 {code, msg, data}, 见 app.response
+type Envelope[T any] struct {
+	Code      int    `json:"code"`
+	Msg       string `json:"msg"`
+	RequestId string `json:"request_id"`
+	Data      T      `json:"data"`
+}
+
+// EnvelopeError 项目内服务返回的业务错误, code 不为 0
+type EnvelopeError struct {
+	StatusCode int
+	Code       int
+	Msg        string
+	RequestId  string
+}
+
+func (e *EnvelopeError) Error() string {
+	return fmt.Sprintf("api error, status: %d, code: %d, msg: %s, request_id: %s", e.StatusCode, e.Code, e.Msg, e.RequestId)
+}
+
+// GetEnvelope 请求项目内的服务的GET接口, 返回响应中的 data
+// 业务出错(code 不为 0)时返回 *EnvelopeError, 包括服务按错误码返回了非 2xx 状态码的情况
+func GetEnvelope[T any](ctx context.Context, url string, options ...Option) (*T, *Response, error) {
+	envelope, resp, err := GetJSON[Envelope[T]](ctx, url, options...)
+	return unwrapEnvelope(envelope, resp, err)
+}
+
+// PostEnvelope 请求项目内的服务的POST接口, 返回响应中的 data, 错误处理同 GetEnvelope
+func PostEnvelope[Req, Resp any](ctx context.Context, url string, req Req, options ...Option) (*Resp, *Response, error) {
+	envelope, resp, err := PostJSON[Req, Envelope[Resp]](ctx, url, req, options...)
+	return unwrapEnvelope(envelope, resp, err)
+}
+
+func unwrapEnvelope[T any](envelope *Envelope[T], resp *Response, err error) (*T, *Response, error) {
+	if err != nil {
+		// 非 2xx 的响应体也是统一的响应结构时转换成业务错误
+		if errEnvelope, ok := DecodeError[Envelope[json.RawMessage]](err); ok && errEnvelope.Code != 0 {
+			httpErr, _ := AsHTTPError(err)
+			return nil, resp, &EnvelopeError{StatusCode: httpErr.StatusCode, Code: errEnvelope.Code, Msg: errEnvelope.Msg, RequestId: errEnvelope.RequestId}
+		}
+		return nil, resp, err
+	}
+	if envelope.Code != 0 {
+		return nil, resp, &EnvelopeError{StatusCode: resp.StatusCode, Code: envelope.Code, Msg: envelope.Msg, RequestId: envelope.RequestId}
+	}
+	return &envelope.Data, resp, nil
+}
+
+// AsEnvelopeError 从错误链中取出 EnvelopeError
+func AsEnvelopeError(err error) (*EnvelopeError, bool) {
+	var envelopeErr *EnvelopeError
+	if errors.As(err, &envelopeErr) {
+		return envelopeErr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,253 @@
+package httptool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 录制和回放HTTP请求, 用于在没有网络的环境中测试调用下游接口的Lib
+//   - RecordTransport 通过真实的 RoundTripper 发起请求, 把请求和响应保存到 fixture 文件
+//   - ReplayTransport 从 fixture 文件中找到和请求匹配的响应返回, 不发起请求
+// 录制时会把认证相关的请求头和响应头替换成 redactedValue, fixture 文件可以提交到代码仓库
+//
+//	rt, _ := httptool.NewCassette("testdata/whois.json")
+//	restore := httptool.SetTransport(rt)
+//	defer restore()
+
+// CassetteRecordEnv 设置了这个环境变量时 NewCassette 录制请求, 否则回放
+const CassetteRecordEnv = "HTTPTOOL_RECORD"
+
+const redactedValue = "[REDACTED]"
+
+// 默认脱敏的请求头和响应头
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-App-Key", "X-Signature"}
+
+// Fixture 录制的一次请求和响应
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+type FixtureRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+type FixtureResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// NewCassette 按环境变量 HTTPTOOL_RECORD 返回录制或者回放 fixture 文件的 RoundTripper
+func NewCassette(path string, matchers ...RequestMatcher) (http.RoundTripper, error) {
+	if os.Getenv(CassetteRecordEnv) != "" {
+		return NewRecordTransport(path, nil), nil
+	}
+	return NewReplayTransport(path, matchers...)
+}
+
+// RecordTransport 录制请求的 RoundTripper, 每录制一次请求就把所有的 fixture 写入文件
+type RecordTransport struct {
+	next          http.RoundTripper
+	path          string
+	redactHeaders []string
+
+	mu       sync.Mutex
+	fixtures []*Fixture
+}
+
+// NewRecordTransport 通过 next 发起请求并录制, next 为 nil 时使用 http.DefaultTransport
+// redactHeaders 是除了默认的认证请求头之外还需要脱敏的头
+func NewRecordTransport(path string, next http.RoundTripper, redactHeaders ...string) *RecordTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordTransport{
+		next:          next,
+		path:          path,
+		redactHeaders: append(append([]string{}, defaultRedactHeaders...), redactHeaders...),
+	}
+}
+
+func (t *RecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	fixture := &Fixture{
+		Request: FixtureRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: redactHeader(req.Header, t.redactHeaders),
+			Body:   string(reqBody),
+		},
+		Response: FixtureResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header, t.redactHeaders),
+			Body:       string(respBody),
+		},
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fixtures = append(t.fixtures, fixture)
+	if err = saveFixtures(t.path, t.fixtures); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func saveFixtures(path string, fixtures []*Fixture) error {
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// readRequestBody 读取请求体用于录制和匹配, 读取后放回请求中
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// RequestMatcher 判断请求和录制的请求是否匹配
+type RequestMatcher func(req *http.Request, body []byte, recorded *FixtureRequest) bool
+
+// MatchMethod 请求方法相同
+func MatchMethod(req *http.Request, body []byte, recorded *FixtureRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL URL完全相同, 包括查询参数
+func MatchURL(req *http.Request, body []byte, recorded *FixtureRequest) bool {
+	return req.URL.String() == recorded.URL
+}
+
+// MatchPath 只比较 Host 和路径, 忽略查询参数, 用于查询参数中有时间戳这类每次都不同的值的请求
+func MatchPath(req *http.Request, body []byte, recorded *FixtureRequest) bool {
+	recordedURL, _, _ := strings.Cut(recorded.URL, "?")
+	return req.URL.Scheme+"://"+req.URL.Host+req.URL.Path == recordedURL
+}
+
+// MatchBody 请求体相同
+func MatchBody(req *http.Request, body []byte, recorded *FixtureRequest) bool {
+	return string(body) == recorded.Body
+}
+
+// MatchHeader 指定的请求头相同
+func MatchHeader(names ...string) RequestMatcher {
+	return func(req *http.Request, body []byte, recorded *FixtureRequest) bool {
+		for _, name := range names {
+			if req.Header.Get(name) != recorded.Header.Get(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ErrNoFixture 回放时找不到和请求匹配的 fixture
+var ErrNoFixture = errors.New("no fixture matches the request")
+
+// ReplayTransport 回放 fixture 的 RoundTripper
+// 按录制的顺序返回第一个匹配且没有被使用过的 fixture, 匹配的都用过之后重复使用最后一个
+type ReplayTransport struct {
+	matchers []RequestMatcher
+
+	mu       sync.Mutex
+	fixtures []*Fixture
+	used     []bool
+}
+
+// NewReplayTransport 从文件中加载 fixture, 没有指定 matchers 时按请求方法和URL匹配
+func NewReplayTransport(path string, matchers ...RequestMatcher) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []*Fixture
+	if err = json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("decode fixture file %s error: %w", path, err)
+	}
+	if len(matchers) == 0 {
+		matchers = []RequestMatcher{MatchMethod, MatchURL}
+	}
+	return &ReplayTransport{matchers: matchers, fixtures: fixtures, used: make([]bool, len(fixtures))}, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	matched := -1
+	for i, fixture := range t.fixtures {
+		if !t.match(req, body, &fixture.Request) {
+			continue
+		}
+		matched = i
+		if !t.used[i] {
+			break
+		}
+	}
+	if matched < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoFixture, req.Method, req.URL)
+	}
+	t.used[matched] = true
+	recorded := t.fixtures[matched].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *ReplayTransport) match(req *http.Request, body []byte, recorded *FixtureRequest) bool {
+	for _, matcher := range t.matchers {
+		if !matcher(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package httptool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 下游返回非 2xx 时错误响应体最多读取的字节数, 错误响应一般很小, 避免异常的下游把大响应读进内存
+const maxErrorBodySize = 64 * 1024
+
+// Response 请求的响应
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// HTTPError 下游返回非 2xx 的响应, 可以用 errors.As 从 Request 返回的错误中取出
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte // 下游返回的错误信息, 最多 maxErrorBodySize 个字节
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("non 2xx response, response code: %d", e.StatusCode)
+}
+
+// DecodeBody 把错误响应体按JSON解析到 v 中
+func (e *HTTPError) DecodeBody(v interface{}) error {
+	return json.Unmarshal(e.Body, v)
+}
+
+// AsHTTPError 从错误链中取出 HTTPError
+func AsHTTPError(err error) (*HTTPError, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+	return nil, false
+}
+
+// DecodeError 把下游返回的错误响应体解析成 E, 不是 HTTPError 或者解析失败时返回 false
+//
+//	if apiErr, ok := httptool.DecodeError[PartnerError](err); ok {
+//		...
+//	}
+func DecodeError[E any](err error) (*E, bool) {
+	httpErr, ok := AsHTTPError(err)
+	if !ok {
+		return nil, false
+	}
+	errBody := new(E)
+	if httpErr.DecodeBody(errBody) != nil {
+		return nil, false
+	}
+	return errBody, true
+}
+
+func isSuccessStatus(statusCode int) bool {
+	return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
+}
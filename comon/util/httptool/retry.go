@@ -0,0 +1,84 @@
+package httptool
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 5 * time.Second
+)
+
+// RetryPolicy 请求失败后的重试策略
+// 连接错误、5xx 和 429 会重试, 响应头中有 Retry-After 时按它要求的时间等待, 否则按指数退避加随机抖动等待
+// POST、PATCH 这类非幂等的请求默认不重试, 确认下游接口支持幂等(比如带了 Idempotency-Key)后才能开启
+type RetryPolicy struct {
+	MaxAttempts        int           // 最多请求几次, 包含第一次请求
+	BaseDelay          time.Duration // 第一次重试前等待的基准时间, 之后每次翻倍, 默认100ms
+	MaxDelay           time.Duration // 退避等待时间的上限, 默认5s
+	AllowNonIdempotent bool          // 是否允许重试非幂等的请求
+}
+
+// WithRetry 设置请求的重试策略, 每次请求单独计算 WithTimeout 设置的超时时间
+func WithRetry(policy RetryPolicy) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		if policy.BaseDelay <= 0 {
+			policy.BaseDelay = defaultRetryBaseDelay
+		}
+		if policy.MaxDelay <= 0 {
+			policy.MaxDelay = defaultRetryMaxDelay
+		}
+		opts.retry = &policy
+		return
+	})
+}
+
+// allowMethod 判断请求方法是否可以重试
+func (p *RetryPolicy) allowMethod(method string) bool {
+	if p.MaxAttempts <= 1 {
+		return false
+	}
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	default:
+		return p.AllowNonIdempotent
+	}
+}
+
+// backoff 计算第 attempt 次请求失败后的等待时间
+func (p *RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// 一半固定一半随机, 避免多个客户端同时重试
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter 解析 Retry-After, 支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
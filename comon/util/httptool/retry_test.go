@@ -0,0 +1,82 @@
+package httptool
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "empty", value: "", wantMin: 0, wantMax: 0},
+		{name: "seconds", value: "3", wantMin: 3 * time.Second, wantMax: 3 * time.Second},
+		{name: "zero seconds", value: "0", wantMin: 0, wantMax: 0},
+		{name: "negative seconds", value: "-1", wantMin: 0, wantMax: 0},
+		{name: "http date in future", value: time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), wantMin: 8 * time.Second, wantMax: 10 * time.Second},
+		{name: "http date in past", value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), wantMin: 0, wantMax: 0},
+		{name: "invalid", value: "soon", wantMin: 0, wantMax: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRetryAfter(tt.value)
+			if got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("parseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{name: "first retry", attempt: 1, wantMin: 50 * time.Millisecond, wantMax: 100 * time.Millisecond},
+		{name: "second retry doubles", attempt: 2, wantMin: 100 * time.Millisecond, wantMax: 200 * time.Millisecond},
+		{name: "capped by max delay", attempt: 5, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "shift overflow capped by max delay", attempt: 80, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "retry after wins", attempt: 1, retryAfter: 3 * time.Second, wantMin: 3 * time.Second, wantMax: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 退避时间带随机抖动, 多算几次检查都落在区间内
+			for i := 0; i < 100; i++ {
+				got := policy.backoff(tt.attempt, tt.retryAfter)
+				if got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("backoff(%d, %v) = %v, want between %v and %v", tt.attempt, tt.retryAfter, got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyAllowMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		method string
+		want   bool
+	}{
+		{name: "single attempt", policy: RetryPolicy{MaxAttempts: 1}, method: http.MethodGet, want: false},
+		{name: "idempotent get", policy: RetryPolicy{MaxAttempts: 3}, method: http.MethodGet, want: true},
+		{name: "lower case put", policy: RetryPolicy{MaxAttempts: 3}, method: "put", want: true},
+		{name: "non idempotent post", policy: RetryPolicy{MaxAttempts: 3}, method: http.MethodPost, want: false},
+		{name: "post allowed explicitly", policy: RetryPolicy{MaxAttempts: 3, AllowNonIdempotent: true}, method: http.MethodPost, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.allowMethod(tt.method); got != tt.want {
+				t.Errorf("allowMethod(%s) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
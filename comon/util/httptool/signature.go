@@ -0,0 +1,33 @@
+package httptool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github/lhh-gh/go-mall/comon/signature"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestSigner 给发出的请求签名
+type requestSigner struct {
+	appKey    string
+	appSecret string
+}
+
+func (s *requestSigner) sign(req *http.Request, body []byte) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	payload := &signature.Payload{
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Query:     req.URL.Query(),
+		BodyHash:  signature.BodyHash(body),
+		Timestamp: time.Now().Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+	}
+	req.Header.Set(signature.HeaderAppKey, s.appKey)
+	req.Header.Set(signature.HeaderTimestamp, strconv.FormatInt(payload.Timestamp, 10))
+	req.Header.Set(signature.HeaderNonce, payload.Nonce)
+	req.Header.Set(signature.HeaderSignature, signature.Sign(s.appSecret, payload))
+}
@@ -0,0 +1,313 @@
+package httptool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 流式上传和下载: 请求体和响应体不整体读进内存, 用于商品图片、大文件导出这类场景
+// 流式请求不记录请求体和响应体的日志
+// 请求的超时时间包含传输请求体和响应体的时间, 大文件需要用 WithTimeout 设置足够长的超时时间
+
+const streamingBodyLog = "(streaming body)"
+
+// ErrRangeNotSupported 续传时下游不支持Range请求, 返回了完整的内容, 而已经写入 io.Writer 的内容无法撤回
+var ErrRangeNotSupported = errors.New("range request not supported by server")
+
+// ProgressFunc 传输进度回调, total 未知时为 -1
+type ProgressFunc func(transferred, total int64)
+
+// WithProgress 设置上传或者下载的进度回调
+func WithProgress(progress ProgressFunc) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.progress = progress
+		return
+	})
+}
+
+// streamBody 流式的请求体
+type streamBody struct {
+	open       func() (io.ReadCloser, error) // 每次请求调用一次, 生成请求体
+	size       int64                         // 请求体的长度, 未知时为 -1
+	rewindable bool                          // 是否可以重新生成, 不能重新生成时不会重试
+}
+
+// WithBody 使用 io.Reader 作为请求体, 实现了 io.Seeker 的(比如文件)可以重试, 重试时回到开始的位置重新发送
+// 请求体不会被关闭, 由调用方负责关闭
+func WithBody(body io.Reader) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		stream := &streamBody{size: -1}
+		seeker, ok := body.(io.Seeker)
+		if !ok {
+			opened := false
+			stream.open = func() (io.ReadCloser, error) {
+				if opened {
+					return nil, errors.New("request body has already been read")
+				}
+				opened = true
+				return io.NopCloser(body), nil
+			}
+			opts.body = stream
+			return
+		}
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return
+		}
+		if end, seekErr := seeker.Seek(0, io.SeekEnd); seekErr == nil {
+			stream.size = end - start
+		}
+		if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+			return
+		}
+		stream.rewindable = true
+		stream.open = func() (io.ReadCloser, error) {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(body), nil
+		}
+		opts.body = stream
+		return
+	})
+}
+
+// Multipart multipart/form-data 请求体, 发送请求时边读文件边编码, 不会把文件整体读进内存
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	fieldName   string
+	fileName    string
+	contentType string
+	value       string
+	reader      io.Reader
+}
+
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(io.Discard).Boundary()}
+}
+
+// AddField 添加普通的表单字段
+func (m *Multipart) AddField(name, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{fieldName: name, value: value})
+	return m
+}
+
+// AddFile 添加文件, 按文件扩展名设置文件的 Content-Type, 文件由调用方负责关闭
+func (m *Multipart) AddFile(fieldName, fileName string, r io.Reader) *Multipart {
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	m.parts = append(m.parts, multipartPart{fieldName: fieldName, fileName: fileName, contentType: contentType, reader: r})
+	return m
+}
+
+// ContentType 请求头 Content-Type 的值
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// open 通过 io.Pipe 边编码边发送, 请求结束时 http.Client 关闭 PipeReader, 编码的协程随之退出
+func (m *Multipart) open() (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		mw := multipart.NewWriter(pw)
+		err := mw.SetBoundary(m.boundary)
+		for _, part := range m.parts {
+			if err != nil {
+				break
+			}
+			err = m.writePart(mw, part)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (m *Multipart) writePart(mw *multipart.Writer, part multipartPart) error {
+	if part.reader == nil {
+		return mw.WriteField(part.fieldName, part.value)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(part.fieldName), quoteEscaper.Replace(part.fileName)))
+	h.Set("Content-Type", part.contentType)
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, part.reader)
+	return err
+}
+
+// WithMultipart 使用 multipart/form-data 请求体, 文件只能读取一次, 所以不会重试
+func WithMultipart(m *Multipart) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.headers["Content-Type"] = m.ContentType()
+		opts.body = &streamBody{open: m.open, size: -1}
+		return
+	})
+}
+
+// Upload 用 multipart/form-data 发起POST请求上传文件
+func Upload(ctx context.Context, url string, m *Multipart, options ...Option) (*Response, error) {
+	newOptions := []Option{WithMultipart(m), WithContext(ctx)}
+	newOptions = append(newOptions, options...)
+	return Do("POST", url, newOptions...)
+}
+
+// downloader 把响应体写入 io.Writer, 重试时用Range请求从已经写入的位置续传
+type downloader struct {
+	w        io.Writer
+	file     *os.File // 下载到文件时下游不支持Range可以清空文件重新下载
+	written  int64    // 已经写入的字节数
+	writeErr error
+}
+
+// Download 发起GET请求, 把响应体写入 w, 配合 WithRetry 使用时传输中断后从中断的位置续传
+// 返回的 Response 中没有响应体
+func Download(ctx context.Context, url string, w io.Writer, options ...Option) (*Response, error) {
+	return download(ctx, url, &downloader{w: w}, options)
+}
+
+// DownloadFile 下载到文件, 文件已经存在时从文件末尾续传
+func DownloadFile(ctx context.Context, url string, path string, options ...Option) (*Response, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := download(ctx, url, &downloader{w: file, file: file, written: offset}, options)
+	if httpErr, ok := AsHTTPError(err); ok && httpErr.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		// 文件已经下载完整
+		if _, total := parseContentRange(httpErr.Header.Get("Content-Range")); total == offset {
+			return &Response{StatusCode: http.StatusPartialContent, Header: httpErr.Header}, nil
+		}
+	}
+	return resp, err
+}
+
+func download(ctx context.Context, url string, d *downloader, options []Option) (*Response, error) {
+	newOptions := append(options, WithContext(ctx), optionFunc(func(opts *requestOption) (err error) {
+		opts.download = d
+		return
+	}))
+	return Do("GET", url, newOptions...)
+}
+
+// rangeHeader 续传时的 Range 请求头
+func (d *downloader) rangeHeader() string {
+	if d.written == 0 {
+		return ""
+	}
+	return "bytes=" + strconv.FormatInt(d.written, 10) + "-"
+}
+
+// receive 把 2xx 响应的响应体写入 w
+func (d *downloader) receive(resp *http.Response, progress ProgressFunc) error {
+	if d.written > 0 && resp.StatusCode != http.StatusPartialContent {
+		if d.file == nil {
+			return ErrRangeNotSupported
+		}
+		// 下游不支持Range, 清空文件从头下载
+		if err := d.file.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		d.written = 0
+	}
+	total := int64(-1)
+	if resp.StatusCode == http.StatusPartialContent {
+		start, rangeTotal := parseContentRange(resp.Header.Get("Content-Range"))
+		if start != d.written {
+			return fmt.Errorf("unexpected content range %q, want start %d", resp.Header.Get("Content-Range"), d.written)
+		}
+		total = rangeTotal
+	} else if resp.ContentLength >= 0 {
+		total = resp.ContentLength
+	}
+	_, err := io.Copy(&progressWriter{d: d, total: total, progress: progress}, resp.Body)
+	return err
+}
+
+// parseContentRange 解析 Content-Range: bytes 100-199/200 或者 bytes */200, 解析不到的值为 -1
+func parseContentRange(contentRange string) (start, total int64) {
+	start, total = -1, -1
+	rangeSpec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return
+	}
+	rangePart, totalPart, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return
+	}
+	if value, err := strconv.ParseInt(totalPart, 10, 64); err == nil {
+		total = value
+	}
+	if startPart, _, ok := strings.Cut(rangePart, "-"); ok {
+		if value, err := strconv.ParseInt(startPart, 10, 64); err == nil {
+			start = value
+		}
+	}
+	return
+}
+
+type progressWriter struct {
+	d        *downloader
+	total    int64
+	progress ProgressFunc
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.d.w.Write(p)
+	w.d.written += int64(n)
+	if err != nil {
+		// 写入本地失败时重试也没用
+		w.d.writeErr = err
+	}
+	if w.progress != nil {
+		w.progress(w.d.written, w.total)
+	}
+	return n, err
+}
+
+type progressReader struct {
+	io.ReadCloser
+	read     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if n > 0 {
+		r.progress(r.read, r.total)
+	}
+	return n, err
+}
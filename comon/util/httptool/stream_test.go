@@ -0,0 +1,29 @@
+package httptool
+
+import "testing"
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentRange string
+		wantStart    int64
+		wantTotal    int64
+	}{
+		{name: "full range", contentRange: "bytes 100-199/200", wantStart: 100, wantTotal: 200},
+		{name: "from zero", contentRange: "bytes 0-99/200", wantStart: 0, wantTotal: 200},
+		{name: "unknown total", contentRange: "bytes 100-199/*", wantStart: 100, wantTotal: -1},
+		{name: "unsatisfied range", contentRange: "bytes */200", wantStart: -1, wantTotal: 200},
+		{name: "empty", contentRange: "", wantStart: -1, wantTotal: -1},
+		{name: "other unit", contentRange: "items 0-9/10", wantStart: -1, wantTotal: -1},
+		{name: "missing total", contentRange: "bytes 100-199", wantStart: -1, wantTotal: -1},
+		{name: "invalid start", contentRange: "bytes x-199/200", wantStart: -1, wantTotal: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, total := parseContentRange(tt.contentRange)
+			if start != tt.wantStart || total != tt.wantTotal {
+				t.Errorf("parseContentRange(%q) = (%d, %d), want (%d, %d)", tt.contentRange, start, total, tt.wantStart, tt.wantTotal)
+			}
+		})
+	}
+}
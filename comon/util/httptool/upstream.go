@@ -0,0 +1,212 @@
+package httptool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github/lhh-gh/go-mall/config"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 按配置 upstreams 调用下游服务, 每个下游服务有自己的 base_url、超时时间、默认请求头和连接池
+// 请求时用 WithUpstream 指定服务名, 请求的 url 只需要写路径:
+//
+//	httptool.Get(ctx, "/building/ping", httptool.WithUpstream("demo"))
+
+// Upstream 下游服务
+type Upstream struct {
+	name     string
+	baseURLs []string
+	next     atomic.Uint64 // 轮询 baseURLs 的计数
+	timeout  time.Duration
+	headers  map[string]string
+	client   *http.Client
+}
+
+var (
+	upstreamsMu sync.Mutex
+	upstreams   = make(map[string]*Upstream) // 服务名 => 下游服务
+)
+
+// GetUpstream 获取配置中的下游服务, 第一次获取时按配置创建连接池
+func GetUpstream(name string) (*Upstream, error) {
+	name = strings.ToLower(name)
+	upstreamsMu.Lock()
+	defer upstreamsMu.Unlock()
+	if upstream, ok := upstreams[name]; ok {
+		return upstream, nil
+	}
+	upstreamConf, ok := config.Upstreams[name]
+	if !ok {
+		return nil, fmt.Errorf("upstream %s is not configured", name)
+	}
+	upstream, err := newUpstream(name, upstreamConf)
+	if err != nil {
+		return nil, err
+	}
+	upstreams[name] = upstream
+	return upstream, nil
+}
+
+func newUpstream(name string, upstreamConf config.UpstreamConfig) (*Upstream, error) {
+	baseURLs := make([]string, 0, len(upstreamConf.BaseURLs)+1)
+	for _, baseURL := range upstreamConf.BaseURLs {
+		baseURLs = append(baseURLs, strings.TrimRight(baseURL, "/"))
+	}
+	if len(baseURLs) == 0 && upstreamConf.BaseURL != "" {
+		baseURLs = append(baseURLs, strings.TrimRight(upstreamConf.BaseURL, "/"))
+	}
+	if len(baseURLs) == 0 {
+		return nil, fmt.Errorf("upstream %s has no base_url", name)
+	}
+	tr, err := newTransport(&upstreamConf)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %w", name, err)
+	}
+	return &Upstream{
+		name:     name,
+		baseURLs: baseURLs,
+		timeout:  upstreamConf.Timeout,
+		headers:  upstreamConf.Headers,
+		client:   &http.Client{Transport: tr},
+	}, nil
+}
+
+// Name 服务名
+func (u *Upstream) Name() string {
+	return u.name
+}
+
+// Client 下游服务的 Client, 使用服务单独的连接池
+func (u *Upstream) Client() *http.Client {
+	return u.client
+}
+
+// URL 拼接请求的完整地址, 配置了多个 base_url 时轮流使用
+func (u *Upstream) URL(path string) string {
+	baseURL := u.baseURLs[0]
+	if len(u.baseURLs) > 1 {
+		baseURL = u.baseURLs[(u.next.Add(1)-1)%uint64(len(u.baseURLs))]
+	}
+	if path == "" {
+		return baseURL
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return baseURL + path
+}
+
+// WithUpstream 请求配置中的下游服务, 请求的 url 为服务的路径
+// 服务配置的超时时间和默认请求头在请求中没有单独设置时生效, 重试时轮到下一个 base_url
+func WithUpstream(name string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.upstream, err = GetUpstream(name)
+		return
+	})
+}
+
+// applyTo 请求中没有单独设置的项使用下游服务的配置
+func (u *Upstream) applyTo(opts *requestOption) {
+	if opts.client == nil {
+		opts.client = u.client
+	}
+	if !opts.timeoutSet && u.timeout > 0 {
+		opts.timeout = u.timeout
+	}
+	for key, value := range u.headers {
+		if !hasHeader(opts.headers, key) {
+			opts.headers[key] = value
+		}
+	}
+}
+
+func hasHeader(headers map[string]string, key string) bool {
+	for name := range headers {
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTransport 创建连接池, 配置中没有设置的项使用默认值
+func newTransport(upstreamConf *config.UpstreamConfig) (*http.Transport, error) {
+	tr := &http.Transport{
+		//Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   durationOr(upstreamConf.DialTimeout, 30*time.Second),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          intOr(upstreamConf.MaxIdleConns, 100),                    // 最大空闲连接, 0 表示不限制
+		IdleConnTimeout:       durationOr(upstreamConf.IdleConnTimeout, 90*time.Second), // 空闲连接在连接池中的最大存活时间, 0表示不限制
+		MaxIdleConnsPerHost:   intOr(upstreamConf.MaxIdleConnsPerHost, 50),              //每个Host (host + port) 的最大空闲连接, 不设置默认用 DefaultMaxIdleConnsPerHost
+		MaxConnsPerHost:       intOr(upstreamConf.MaxConnsPerHost, 50),                  // 每个Host 的最大连接, 0 表示不限制 与 MaxIdleConnsPerHost 相等(尽量使用空闲连接)
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second, // waiting time for TLS handshake, zero means no timeout
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if upstreamConf.Proxy != "" {
+		proxyURL, err := url.Parse(upstreamConf.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %w", upstreamConf.Proxy, err)
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+	tlsConfig, err := newTLSConfig(&upstreamConf.TLS)
+	if err != nil {
+		return nil, err
+	}
+	tr.TLSClientConfig = tlsConfig
+	return tr, nil
+}
+
+func newTLSConfig(tlsConf *config.UpstreamTLS) (*tls.Config, error) {
+	if *tlsConf == (config.UpstreamTLS{}) {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName:         tlsConf.ServerName,
+		InsecureSkipVerify: tlsConf.InsecureSkipVerify,
+	}
+	if tlsConf.CAFile != "" {
+		caPem, err := os.ReadFile(tlsConf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPem) {
+			return nil, errors.New("no certificate found in " + tlsConf.CAFile)
+		}
+	}
+	if tlsConf.CertFile != "" || tlsConf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConf.CertFile, tlsConf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func durationOr(value, defaultValue time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func intOr(value, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
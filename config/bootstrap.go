@@ -31,4 +31,18 @@ func init() {
 
 	vp.UnmarshalKey("database", &Database)
 	vp.UnmarshalKey("redis", &Redis)
+	vp.UnmarshalKey("rate_limit", &RateLimit)
+	vp.UnmarshalKey("auth", &Auth)
+	vp.UnmarshalKey("cors", &Cors)
+	vp.UnmarshalKey("security", &Security)
+	vp.UnmarshalKey("timeout", &Timeout)
+	vp.UnmarshalKey("idempotency", &Idempotency)
+	vp.UnmarshalKey("alert", &Alert)
+	vp.UnmarshalKey("access_log", &AccessLog)
+	vp.UnmarshalKey("open_api", &OpenApi)
+	vp.UnmarshalKey("ip_intel", &IpIntel)
+	vp.UnmarshalKey("ip_access", &IpAccess)
+	vp.UnmarshalKey("feature_flag", &FeatureFlags)
+	vp.UnmarshalKey("circuit_breaker", &Breaker)
+	vp.UnmarshalKey("upstreams", &Upstreams)
 }
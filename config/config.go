@@ -4,9 +4,23 @@ import "time"
 
 // 项目通过这里的变量读取应用配置中的对应项
 var (
-	App      *appConfig
-	Database *databaseConfig
-	Redis    *redisConfig
+	App          *appConfig
+	Database     *databaseConfig
+	Redis        *redisConfig
+	RateLimit    map[string]RateLimitRule // 路由组名 => 限流规则
+	Auth         *authConfig
+	Cors         map[string]CorsPolicy // 路由组名 => 跨域策略
+	Security     *securityConfig
+	Timeout      *timeoutConfig
+	Idempotency  *idempotencyConfig
+	Alert        *alertConfig
+	AccessLog    *accessLogConfig
+	OpenApi      *openApiConfig
+	IpIntel      *ipIntelConfig
+	IpAccess     map[string]IpAccessRule // 路由组名 => IP访问规则
+	FeatureFlags map[string]FeatureFlag  // 开关名 => 开关配置
+	Breaker      *breakerConfig
+	Upstreams    map[string]UpstreamConfig // 下游服务名 => 调用下游服务的配置
 )
 
 type appConfig struct {
@@ -21,17 +35,25 @@ type appConfig struct {
 		DefaultSize int `mapstructure:"default_size"`
 		MaxSize     int `mapstructure:"max_size"`
 	}
+	HttpCache struct {
+		CacheControl string            `mapstructure:"cache_control"` // GET接口默认的Cache-Control, 为空时不设置
+		Routes       map[string]string `mapstructure:"routes"`        // 路由(gin的FullPath) => Cache-Control
+	} `mapstructure:"http_cache"`
+	// 可信的反向代理(IP或者网段), 只有来自这些地址的请求才从 X-Forwarded-For 中读取客户端IP, 不配置时直接使用连接的对端地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type databaseConfig struct {
-	Type   string          `mapstructure:"type"`
-	Master DbConnectOption `mapstructure:"master"`
-	Slave  DbConnectOption `mapstructure:"slave"`
+	Type     string            `mapstructure:"type"`
+	Master   DbConnectOption   `mapstructure:"master"`
+	Replicas []DbConnectOption `mapstructure:"replicas"` // 从库, 读请求在健康的从库间轮流分配, 不配置时读写都使用主库
+	// 从库健康检查的间隔, 检查失败的从库不再分配读请求, 恢复后重新加入
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
 }
 
 type DbConnectOption struct {
 	DSN         string        `mapstructure:"dsn"`
-	MaxOpenConn int           `mapstructure:"maxopen""`
+	MaxOpenConn int           `mapstructure:"maxopen"`
 	MaxIdleConn int           `mapstructure:"maxidle"`
 	MaxLifeTime time.Duration `mapstructure:"maxlifetime"`
 }
@@ -43,3 +65,174 @@ type redisConfig struct {
 	PoolSize int    `mapstructure:"pool_size"`
 	DB       int    `mapstructure:"db"`
 }
+
+// RateLimitRule 路由组的限流规则
+type RateLimitRule struct {
+	Algorithm string        `mapstructure:"algorithm"` // token_bucket 或 sliding_window
+	KeyBy     string        `mapstructure:"key_by"`    // 限流维度: ip、user、open_app
+	Limit     int           `mapstructure:"limit"`     // 每个窗口内允许的请求数
+	Window    time.Duration `mapstructure:"window"`    // 时间窗口, 比如 1s、1m
+	Burst     int           `mapstructure:"burst"`     // 令牌桶容量, 为0时等于limit
+}
+
+// 用户认证Token相关的配置
+type authConfig struct {
+	Issuer          string        `mapstructure:"issuer"`
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+	SigningKeyId    string        `mapstructure:"signing_key_id"` // 签发Token使用的密钥, 其他密钥只用于验证轮换前签发的Token
+	Keys            []JwtKey      `mapstructure:"keys"`
+}
+
+// JwtKey 签名密钥, HS256 使用 secret, RS256 使用PEM格式的密钥文件
+type JwtKey struct {
+	Id             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"`
+	Secret         string `mapstructure:"secret"`
+	PrivateKeyFile string `mapstructure:"private_key_file"` // 只用于验证的旧密钥可以不配置私钥
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+// CorsPolicy 路由组的跨域策略
+type CorsPolicy struct {
+	AllowOrigins     []string      `mapstructure:"allow_origins"` // 支持 * 和通配子域名, 比如 https://*.go-mall.com
+	AllowMethods     []string      `mapstructure:"allow_methods"`
+	AllowHeaders     []string      `mapstructure:"allow_headers"`
+	ExposeHeaders    []string      `mapstructure:"expose_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"` // 预检请求结果的缓存时间
+}
+
+// 安全相关的响应头, 不配置时使用按环境区分的默认值, 配置为 off 时不设置
+type securityConfig struct {
+	HSTS                  string `mapstructure:"hsts"`
+	ContentTypeOptions    string `mapstructure:"content_type_options"`
+	FrameOptions          string `mapstructure:"frame_options"`
+	ContentSecurityPolicy string `mapstructure:"csp"`
+	ReferrerPolicy        string `mapstructure:"referrer_policy"`
+}
+
+// 接口执行的超时时间
+type timeoutConfig struct {
+	Default time.Duration            `mapstructure:"default"` // 为0时不限制
+	Routes  map[string]time.Duration `mapstructure:"routes"`  // 路由(gin的FullPath) => 超时时间, 为0时不限制
+}
+
+// 幂等请求的配置
+type idempotencyConfig struct {
+	TTL           time.Duration            `mapstructure:"ttl"`            // 已完成请求的响应保存多久
+	ProcessingTTL time.Duration            `mapstructure:"processing_ttl"` // 处理中状态最多保留多久, 防止进程崩溃后Key一直被占用
+	Routes        map[string]time.Duration `mapstructure:"routes"`         // 路由(gin的FullPath) => 响应保存时间
+	MaxBodySize   int64                    `mapstructure:"max_body_size"`  // 计算请求指纹时最多读取的请求体大小, 默认1MB
+}
+
+// 告警渠道的配置, 没有配置的渠道不启用
+type alertConfig struct {
+	DedupWindow time.Duration `mapstructure:"dedup_window"` // 同一个告警的去重窗口
+	Webhook     struct {
+		Url string `mapstructure:"url"`
+	} `mapstructure:"webhook"`
+	Email AlertEmail `mapstructure:"email"`
+	File  struct {
+		Path string `mapstructure:"path"`
+	} `mapstructure:"file"`
+}
+
+// AlertEmail 告警邮件的SMTP配置
+type AlertEmail struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// 访问日志的配置
+type accessLogConfig struct {
+	Default AccessLogOption            `mapstructure:"default"`
+	Routes  map[string]AccessLogOption `mapstructure:"routes"` // 路由(gin的FullPath) => 选项, 没有设置的项使用 default 中的值
+}
+
+// AccessLogOption 访问日志选项
+type AccessLogOption struct {
+	CaptureBody      *bool    `mapstructure:"capture_body"`       // 是否记录请求体和响应体
+	MaxBodySize      int      `mapstructure:"max_body_size"`      // 请求体和响应体最多记录的字节数, 超出部分截断
+	SkipContentTypes []string `mapstructure:"skip_content_types"` // 这些类型(前缀匹配)的请求体和响应体不记录, 比如 multipart/form-data
+	Headers          []string `mapstructure:"headers"`            // 需要记录的请求头
+	LogStart         *bool    `mapstructure:"log_start"`          // 是否在请求开始时记录一条 access_start 日志
+}
+
+// 开放接口请求签名的配置
+type openApiConfig struct {
+	TimestampSkew time.Duration `mapstructure:"timestamp_skew"` // 请求时间戳与服务器时间允许的最大偏差
+	MaxBodySize   int64         `mapstructure:"max_body_size"`  // 参与签名的请求体的最大字节数
+}
+
+// IP情报的配置
+type ipIntelConfig struct {
+	MMDBPath      string        `mapstructure:"mmdb_path"`      // 离线 GeoIP 库(MMDB格式)的路径
+	WhoisFallback bool          `mapstructure:"whois_fallback"` // 离线库查不到时是否调用 ipwhois 查询
+	CacheTTL      time.Duration `mapstructure:"cache_ttl"`
+	CacheSize     int           `mapstructure:"cache_size"` // 进程内最多缓存多少个IP的查询结果
+	// 查不到或者查询失败的结果的缓存时间, 期间同一个IP不再查询
+	NegativeCacheTTL time.Duration `mapstructure:"negative_cache_ttl"`
+}
+
+// IpAccessRule 路由组的IP访问规则
+type IpAccessRule struct {
+	AllowCidrs     []string `mapstructure:"allow_cidrs"` // 配置后只允许这些网段访问, 也可以是单个IP
+	DenyCidrs      []string `mapstructure:"deny_cidrs"`
+	AllowCountries []string `mapstructure:"allow_countries"` // 两位国家代码, 配置后只允许这些国家的IP访问
+	DenyCountries  []string `mapstructure:"deny_countries"`
+	// 查不到IP所属的国家或者查询失败时是否拒绝访问, 默认放行
+	CountryFailClosed bool `mapstructure:"country_fail_closed"`
+}
+
+// FeatureFlag 功能开关, 可以被Redis中的同名开关覆盖
+type FeatureFlag struct {
+	Enabled    bool    `mapstructure:"enabled"`    // 总开关, 关闭时对所有用户都关闭
+	Percentage *int    `mapstructure:"percentage"` // 按用户ID灰度的比例 0~100, 不配置时对所有用户开启
+	UserIds    []int64 `mapstructure:"user_ids"`   // 不受灰度比例限制, 始终开启的用户
+}
+
+// 调用外部HTTP接口的熔断配置
+type breakerConfig struct {
+	Default BreakerPolicy   `mapstructure:"default"`
+	Hosts   []BreakerPolicy `mapstructure:"hosts"` // 按Host单独设置, 没有设置的项使用 default 中的值
+}
+
+// BreakerPolicy 熔断策略
+type BreakerPolicy struct {
+	Host             string        `mapstructure:"host"` // 带端口时包含端口, 比如 localhost:8080
+	Disabled         bool          `mapstructure:"disabled"`
+	Window           time.Duration `mapstructure:"window"`             // 统计失败率的时间窗口
+	MinRequests      int           `mapstructure:"min_requests"`       // 窗口内请求数达到这个值才计算失败率
+	FailureRatio     float64       `mapstructure:"failure_ratio"`      // 失败率达到这个值时熔断
+	OpenTimeout      time.Duration `mapstructure:"open_timeout"`       // 熔断多久后进入半开状态
+	HalfOpenRequests int           `mapstructure:"half_open_requests"` // 半开状态放行的探测请求数, 全部成功后关闭熔断
+}
+
+// UpstreamConfig 调用下游服务的配置, 每个下游服务使用单独的连接池
+type UpstreamConfig struct {
+	BaseURL  string            `mapstructure:"base_url"`
+	BaseURLs []string          `mapstructure:"base_urls"` // 配置多个时轮流请求, 配置后忽略 base_url
+	Timeout  time.Duration     `mapstructure:"timeout"`   // 请求的超时时间, 请求中用 WithTimeout 设置的优先
+	Headers  map[string]string `mapstructure:"headers"`   // 默认的请求头, 请求中设置的同名请求头优先
+	Proxy    string            `mapstructure:"proxy"`     // 代理地址, 比如 http://127.0.0.1:7890
+	// 连接池
+	DialTimeout         time.Duration `mapstructure:"dial_timeout"`
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+	MaxIdleConns        int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"`
+	TLS                 UpstreamTLS   `mapstructure:"tls"`
+}
+
+type UpstreamTLS struct {
+	CAFile             string `mapstructure:"ca_file"`   // 自签名的下游服务的CA证书
+	CertFile           string `mapstructure:"cert_file"` // 双向认证的客户端证书
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // 不验证下游服务的证书, 只能在开发环境使用
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/logic/do"
+	"time"
+)
+
+// Refresh Token 在 Redis 中只保存摘要, 即使缓存数据泄露也拿不到可用的Token
+func refreshTokenKey(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, hex.EncodeToString(sum[:]))
+}
+
+// SetRefreshToken 保存 Refresh Token 对应的会话
+func SetRefreshToken(ctx context.Context, refreshToken string, session *do.TokenSession) error {
+	jsonDataBytes, _ := json.Marshal(session)
+	ttl := time.Until(session.ExpiresAt)
+	_, err := Redis().Set(ctx, refreshTokenKey(refreshToken), jsonDataBytes, ttl).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetRefreshToken 读取 Refresh Token 对应的会话, Token不存在或已被吊销时返回 nil
+func GetRefreshToken(ctx context.Context, refreshToken string) (*do.TokenSession, error) {
+	jsonBytes, err := Redis().Get(ctx, refreshTokenKey(refreshToken)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return nil, err
+	}
+	session := new(do.TokenSession)
+	if err = json.Unmarshal(jsonBytes, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// DelRefreshToken 删除 Refresh Token, 返回Token删除前是否存在
+// 刷新Token时通过删除结果判断并发请求中只有一个能用同一个 Refresh Token 换到新Token
+func DelRefreshToken(ctx context.Context, refreshToken string) (bool, error) {
+	deleted, err := Redis().Del(ctx, refreshTokenKey(refreshToken)).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// SetSessionRevoked 吊销会话, 会话签发的 Access Token 在 ttl 内都不能再使用
+func SetSessionRevoked(ctx context.Context, sessionId string, ttl time.Duration) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_REVOKED_SESSION, sessionId)
+	_, err := Redis().Set(ctx, redisKey, 1, ttl).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// IsSessionRevoked 会话是否已经被吊销
+func IsSessionRevoked(ctx context.Context, sessionId string) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_REVOKED_SESSION, sessionId)
+	exists, err := Redis().Exists(ctx, redisKey).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return false, err
+	}
+
+	return exists > 0, nil
+}
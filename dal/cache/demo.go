@@ -47,8 +47,6 @@ func GetDemoOrderStruct(ctx context.Context, orderNo string) (*DummyDemoOrder, e
 	err := Redis().HGetAll(ctx, redisKey).Scan(&data)
 	// 从 Redis 中获取所有字段并映射到结构体
 
-	Redis().Get(ctx, redisKey).String() // 此行未使用结果，可能为冗余代码
-
 	if err != nil {
 		logger.New(ctx).Error("redis error", "err", err) // 如果出错，记录错误日志
 		return nil, err
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/logger"
+)
+
+// FeatureFlagStore 功能开关覆盖配置的Redis存储, 在 main 中通过 featureflag.SetOverrideStore 设置给功能开关
+type FeatureFlagStore struct{}
+
+func (FeatureFlagStore) GetOverrides(ctx context.Context) (map[string]string, error) {
+	return GetFeatureFlagOverrides(ctx)
+}
+
+func (FeatureFlagStore) SetOverride(ctx context.Context, name string, flagJson []byte) error {
+	return SetFeatureFlagOverride(ctx, name, flagJson)
+}
+
+func (FeatureFlagStore) DelOverride(ctx context.Context, name string) error {
+	return DelFeatureFlagOverride(ctx, name)
+}
+
+// GetFeatureFlagOverrides 读取Redis中所有覆盖配置的功能开关, 开关名 => 开关配置的JSON
+func GetFeatureFlagOverrides(ctx context.Context) (map[string]string, error) {
+	overrides, err := Redis().HGetAll(ctx, enum.REDIS_KEY_FEATURE_FLAGS).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// SetFeatureFlagOverride 在Redis中覆盖功能开关的配置
+func SetFeatureFlagOverride(ctx context.Context, name string, flagJson []byte) error {
+	if err := Redis().HSet(ctx, enum.REDIS_KEY_FEATURE_FLAGS, name, flagJson).Err(); err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// DelFeatureFlagOverride 删除功能开关在Redis中的覆盖配置, 恢复使用配置文件中的配置
+func DelFeatureFlagOverride(ctx context.Context, name string) error {
+	if err := Redis().HDel(ctx, enum.REDIS_KEY_FEATURE_FLAGS, name).Err(); err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/logger"
+	"time"
+)
+
+// 幂等请求的处理状态
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord 幂等请求的记录
+type IdempotencyRecord struct {
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint"` // 请求的指纹, 同一个Key只能用于完全相同的请求
+	HttpStatus  int    `json:"http_status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// AcquireIdempotencyKey 占用幂等Key, Key已经存在时返回 false
+func AcquireIdempotencyKey(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (bool, error) {
+	jsonDataBytes, _ := json.Marshal(record)
+	ok, err := Redis().SetNX(ctx, enum.REDIS_KEY_IDEMPOTENCY_PREFIX+key, jsonDataBytes, ttl).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// GetIdempotencyRecord 读取幂等记录, 不存在时返回 nil
+func GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	jsonBytes, err := Redis().Get(ctx, enum.REDIS_KEY_IDEMPOTENCY_PREFIX+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return nil, err
+	}
+	record := new(IdempotencyRecord)
+	if err = json.Unmarshal(jsonBytes, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// SetIdempotencyRecord 保存幂等记录
+func SetIdempotencyRecord(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	jsonDataBytes, _ := json.Marshal(record)
+	_, err := Redis().Set(ctx, enum.REDIS_KEY_IDEMPOTENCY_PREFIX+key, jsonDataBytes, ttl).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// DelIdempotencyRecord 删除幂等记录, 请求处理失败后允许客户端用同一个Key重试
+func DelIdempotencyRecord(ctx context.Context, key string) error {
+	if err := Redis().Del(ctx, enum.REDIS_KEY_IDEMPOTENCY_PREFIX+key).Err(); err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
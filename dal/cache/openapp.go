@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/logic/do"
+	"time"
+)
+
+// OpenAppTTL 应用信息缓存的有效期, 应用状态变更时会主动删除缓存
+const OpenAppTTL = 10 * time.Minute
+
+// SetOpenApp 缓存应用信息
+func SetOpenApp(ctx context.Context, openApp *do.OpenApp) error {
+	jsonDataBytes, _ := json.Marshal(openApp)
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_OPEN_APP, openApp.AppKey)
+	_, err := Redis().Set(ctx, redisKey, jsonDataBytes, OpenAppTTL).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetOpenApp 读取缓存的应用信息, 缓存不存在时返回 nil
+func GetOpenApp(ctx context.Context, appKey string) (*do.OpenApp, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_OPEN_APP, appKey)
+	jsonBytes, err := Redis().Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return nil, err
+	}
+	openApp := new(do.OpenApp)
+	if err = json.Unmarshal(jsonBytes, openApp); err != nil {
+		return nil, err
+	}
+
+	return openApp, nil
+}
+
+// DelOpenApp 删除应用信息的缓存
+func DelOpenApp(ctx context.Context, appKey string) error {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_OPEN_APP, appKey)
+	if err := Redis().Del(ctx, redisKey).Err(); err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// AcquireOpenApiNonce 占用请求的随机串, 随机串已经被使用过时返回 false
+// ttl 要不小于允许的时间戳偏差的两倍, 保证时间戳有效期内的重放都能被识别
+func AcquireOpenApiNonce(ctx context.Context, appKey, nonce string, ttl time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_OPEN_API_NONCE, appKey, nonce)
+	ok, err := Redis().SetNX(ctx, redisKey, 1, ttl).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return false, err
+	}
+
+	return ok, nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/logger"
+	"time"
+)
+
+// UserPermissionsTTL 用户权限缓存的有效期, 角色和权限变更时会主动删除缓存, 这里只是兜底
+const UserPermissionsTTL = 10 * time.Minute
+
+// SetUserPermissions 缓存用户拥有的权限标识
+func SetUserPermissions(ctx context.Context, userId int64, codes []string) error {
+	if codes == nil {
+		codes = []string{}
+	}
+	jsonDataBytes, _ := json.Marshal(codes)
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_PERMISSIONS, userId)
+	_, err := Redis().Set(ctx, redisKey, jsonDataBytes, UserPermissionsTTL).Result()
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetUserPermissions 读取缓存的用户权限标识, 缓存不存在时返回 nil
+func GetUserPermissions(ctx context.Context, userId int64) ([]string, error) {
+	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_PERMISSIONS, userId)
+	jsonBytes, err := Redis().Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return nil, err
+	}
+	codes := make([]string, 0)
+	if err = json.Unmarshal(jsonBytes, &codes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DelUserPermissions 删除用户的权限缓存
+func DelUserPermissions(ctx context.Context, userIds ...int64) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	redisKeys := make([]string, 0, len(userIds))
+	for _, userId := range userIds {
+		redisKeys = append(redisKeys, fmt.Sprintf(enum.REDIS_KEY_USER_PERMISSIONS, userId))
+	}
+	if err := Redis().Del(ctx, redisKeys...).Err(); err != nil {
+		logger.New(ctx).Error("redis error", "err", err)
+		return err
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/metrics"
 	"github/lhh-gh/go-mall/config"
 	"time"
 )
@@ -13,7 +14,9 @@ func Redis() *redis.Client {
 	return redisClient
 }
 
-func init() {
+// InitRedis 创建Redis客户端, 连接不上Redis时 panic 让项目停止启动
+// 在 main 中注册路由前调用, 不放在包的 init 中, 引用 cache 包的单元测试可以连接测试用的Redis
+func InitRedis() {
 	redisClient = redis.NewClient(&redis.Options{
 		Addr:         config.Redis.Addr,
 		Password:     config.Redis.Password,
@@ -23,7 +26,11 @@ func init() {
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		PoolTimeout:  30 * time.Second,
+		// 命令使用 ctx 中的截止时间, 请求超时后Redis命令随之取消
+		ContextTimeoutEnabled: true,
 	})
+	redisClient.AddHook(redisMetricsHook{})
+	metrics.RegisterRedisPoolStats(redisClient)
 
 	if err := redisClient.Ping(context.Background()).Err(); err != nil {
 		// 连接不上redis 让项目停止启动
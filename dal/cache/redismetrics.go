@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"net"
+	"time"
+)
+
+// redisMetricsHook 记录Redis命令的耗时指标
+type redisMetricsHook struct{}
+
+func (redisMetricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisMetricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		metrics.RedisCommandDuration.WithLabelValues(cmd.Name(), redisResult(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (redisMetricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		metrics.RedisCommandDuration.WithLabelValues("pipeline", redisResult(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// redisResult 命令结果, Key不存在不算错误
+func redisResult(err error) string {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return "ok"
+	}
+	return "error"
+}
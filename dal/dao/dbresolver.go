@@ -0,0 +1,136 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"gorm.io/gorm"
+	"sync/atomic"
+	"time"
+)
+
+// 读写分离: 由 gorm 的 dbresolver 插件按操作类型选择连接
+//   - 写操作、事务和 SELECT ... FOR UPDATE 使用主库
+//   - 读操作在健康的从库间轮流分配, 所有从库都不健康时使用主库
+//   - 后台定时 Ping 从库, 失败的从库被摘除, 恢复后重新加入
+// 写入后马上读取的场景(读自己的写)用 ForceMaster(ctx) 避免从库复制延迟读到旧数据
+
+const (
+	defaultReplicaHealthCheckInterval = 10 * time.Second
+	replicaPingTimeout                = 3 * time.Second
+)
+
+type forceMasterKey struct{}
+
+// ForceMaster 返回强制使用主库的 ctx, 用这个 ctx 创建的DAO执行的查询都会读主库
+func ForceMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceMasterKey{}, true)
+}
+
+func isForceMaster(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	force, _ := ctx.Value(forceMasterKey{}).(bool)
+	return force
+}
+
+// replica 从库
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaResolver 给 dbresolver 提供跳过不健康从库的读负载均衡策略, 同时作为 gorm 插件处理 ForceMaster 和从库全部不健康的情况
+type replicaResolver struct {
+	master   gorm.ConnPool
+	replicas []*replica
+	byPool   map[gorm.ConnPool]*replica
+	next     atomic.Uint64
+}
+
+func newReplicaResolver(master gorm.ConnPool, replicas []*replica) *replicaResolver {
+	r := &replicaResolver{master: master, replicas: replicas, byPool: make(map[gorm.ConnPool]*replica, len(replicas))}
+	for _, rep := range replicas {
+		r.byPool[rep.db] = rep
+	}
+	return r
+}
+
+// Resolve 实现 dbresolver.Policy, 在健康的从库间轮流分配读请求
+func (r *replicaResolver) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, connPool := range connPools {
+		if r.isHealthy(connPool) {
+			healthy = append(healthy, connPool)
+		}
+	}
+	if len(healthy) == 0 {
+		return r.master
+	}
+	return healthy[(r.next.Add(1)-1)%uint64(len(healthy))]
+}
+
+func (r *replicaResolver) isHealthy(connPool gorm.ConnPool) bool {
+	rep, ok := r.byPool[connPool]
+	return !ok || rep.healthy.Load()
+}
+
+func (r *replicaResolver) Name() string {
+	return "gomall:replica_resolver"
+}
+
+// Initialize 注册在执行SQL前的回调, 处理 ForceMaster 和选中的从库不健康的情况
+// dbresolver 的回调注册在所有回调之前, 这里的回调在它选择连接之后执行
+// dbresolver 只有一个从库时不经过 Resolve, 需要在这里把不健康的从库换成主库
+func (r *replicaResolver) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("gomall:replica_resolver_query", r.afterResolve); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("gomall:replica_resolver_row", r.afterResolve); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("gomall:replica_resolver_raw", r.afterResolve)
+}
+
+func (r *replicaResolver) afterResolve(db *gorm.DB) {
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if isForceMaster(db.Statement.Context) || !r.isHealthy(db.Statement.ConnPool) {
+		db.Statement.ConnPool = r.master
+	}
+}
+
+// healthCheck 定时 Ping 从库, 更新从库的健康状态
+func (r *replicaResolver) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, rep := range r.replicas {
+			r.checkReplica(rep)
+		}
+	}
+}
+
+func (r *replicaResolver) checkReplica(rep *replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+	defer cancel()
+	err := rep.db.PingContext(ctx)
+	healthy := err == nil
+	if rep.healthy.Swap(healthy) != healthy {
+		if healthy {
+			logger.New(ctx).Info("db replica recovered", "replica", rep.name)
+		} else {
+			logger.New(ctx).Error("db replica ejected", "replica", rep.name, "err", err)
+		}
+	}
+	if healthy {
+		metrics.DBReplicaUp.WithLabelValues(rep.name).Set(1)
+	} else {
+		metrics.DBReplicaUp.WithLabelValues(rep.name).Set(0)
+	}
+}
@@ -17,7 +17,7 @@ func NewDemoDao(ctx context.Context) *DemoDao {
 
 func (demo *DemoDao) GetAllDemos() (demos []*model.DemoOrder, err error) {
 
-	err = DB().WithContext(demo.ctx).Find(&demos).Error
+	err = dbFrom(demo.ctx).Find(&demos).Error
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +31,19 @@ func (demo *DemoDao) CreateDemoOrder(demoOrder *do.DemoOrder) (*model.DemoOrder,
 	if err != nil {
 		return nil, err
 	}
-	err = DB().WithContext(demo.ctx).
+	err = dbFrom(demo.ctx).
 		Create(model).Error
 	return model, err
 }
+
+// CreateDemoOrderGoodsSnapshot 写订单商品快照, 需要和订单在同一个事务中写入, 见 WithTx
+func (demo *DemoDao) CreateDemoOrderGoodsSnapshot(demoOrder *model.DemoOrder, orderGoodsId int64) error {
+	snapshot := &model.DemoOrderGoodsSnapshot{
+		OrderId:      demoOrder.Id,
+		OrderNo:      demoOrder.OrderNo,
+		OrderGoodsId: orderGoodsId,
+		UserId:       demoOrder.UserId,
+		BillMoney:    demoOrder.BillMoney,
+	}
+	return dbFrom(demo.ctx).Create(snapshot).Error
+}
@@ -1,46 +1,96 @@
 package dao
 
 import (
+	"database/sql"
+	"fmt"
+	"github/lhh-gh/go-mall/comon/metrics"
 	"github/lhh-gh/go-mall/config"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
-var _DbMaster *gorm.DB
-var _DbSlave *gorm.DB
+var _Db *gorm.DB
 
-// DB 返回只读实例
+// DB 返回数据库实例, 写操作和事务使用主库, 读操作使用从库, 见 dbresolver.go
 func DB() *gorm.DB {
-	return _DbSlave
+	return _Db
 }
 
-// DBMaster 返回主库实例
+// DBMaster 返回强制使用主库的实例, 读操作也使用主库
 func DBMaster() *gorm.DB {
-	return _DbMaster
+	return _Db.Clauses(dbresolver.Write)
 }
 
-func init() {
+// InitDB 连接主库并注册从库, 连接不上主库时 panic 让项目停止启动
+// 在 main 中注册路由前调用, 不放在包的 init 中, 引用 dao 包的单元测试不需要连接MySQL
+func InitDB() {
 	//logger.New(context.TODO()).Info("database info", "db", config.Database)
-	_DbMaster = initDB(config.Database.Master)
-	_DbSlave = initDB(config.Database.Slave)
+	_Db = initDB("master", config.Database.Master)
+	if len(config.Database.Replicas) > 0 {
+		initReplicas(_Db, config.Database.Replicas)
+	}
 }
 
-func initDB(option config.DbConnectOption) *gorm.DB {
+func initDB(name string, option config.DbConnectOption) *gorm.DB {
 	db, err := gorm.Open(
 		mysql.Open(option.DSN),
 		&gorm.Config{
 			Logger: NewGormLogger(),
+			// 由 initDB 自己 Ping 主库, 从库启动时连不上不影响服务启动, 由健康检查摘除
+			DisableAutomaticPing: true,
 		},
 	)
 	if err != nil {
 		panic(err)
 	}
+	if err = db.Use(gormMetricsPlugin{}); err != nil {
+		panic(err)
+	}
 	sqlDb, _ := db.DB()
-	sqlDb.SetMaxOpenConns(option.MaxOpenConn)
-	sqlDb.SetMaxIdleConns(option.MaxIdleConn)
-	sqlDb.SetConnMaxLifetime(option.MaxLifeTime)
+	setPoolOptions(sqlDb, option)
 	if err = sqlDb.Ping(); err != nil {
 		panic(err)
 	}
+	metrics.RegisterDBStats(name, sqlDb)
 	return db
 }
+
+// initReplicas 给主库实例注册从库
+func initReplicas(db *gorm.DB, options []config.DbConnectOption) {
+	replicas := make([]*replica, 0, len(options))
+	dialectors := make([]gorm.Dialector, 0, len(options))
+	for i, option := range options {
+		sqlDb, err := sql.Open("mysql", option.DSN)
+		if err != nil {
+			panic(err)
+		}
+		setPoolOptions(sqlDb, option)
+		rep := &replica{name: fmt.Sprintf("replica-%d", i), db: sqlDb}
+		metrics.RegisterDBStats(rep.name, sqlDb)
+		replicas = append(replicas, rep)
+		dialectors = append(dialectors, mysql.New(mysql.Config{Conn: sqlDb, SkipInitializeWithVersion: true}))
+	}
+
+	resolver := newReplicaResolver(db.ConnPool, replicas)
+	for _, rep := range replicas {
+		resolver.checkReplica(rep)
+	}
+	if err := db.Use(dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: resolver})); err != nil {
+		panic(err)
+	}
+	if err := db.Use(resolver); err != nil {
+		panic(err)
+	}
+	interval := config.Database.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaHealthCheckInterval
+	}
+	go resolver.healthCheck(interval)
+}
+
+func setPoolOptions(sqlDb *sql.DB, option config.DbConnectOption) {
+	sqlDb.SetMaxOpenConns(option.MaxOpenConn)
+	sqlDb.SetMaxIdleConns(option.MaxIdleConn)
+	sqlDb.SetConnMaxLifetime(option.MaxLifeTime)
+}
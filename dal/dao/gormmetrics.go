@@ -0,0 +1,78 @@
+package dao
+
+import (
+	"errors"
+	"github/lhh-gh/go-mall/comon/metrics"
+	"gorm.io/gorm"
+	"time"
+)
+
+// gormMetricsPlugin 通过GORM的回调记录SQL执行耗时和错误的指标
+type gormMetricsPlugin struct{}
+
+const gormMetricsStartKey = "gomall:metrics_start"
+
+func (gormMetricsPlugin) Name() string {
+	return "gomall:metrics"
+}
+
+// Initialize 在GORM每种操作的回调前后各注册一个回调, 计算SQL的执行耗时
+func (p gormMetricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("gomall:metrics_before_create", p.before); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("gomall:metrics_after_create", p.after("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("gomall:metrics_before_query", p.before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("gomall:metrics_after_query", p.after("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("gomall:metrics_before_update", p.before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("gomall:metrics_after_update", p.after("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("gomall:metrics_before_delete", p.before); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("gomall:metrics_after_delete", p.after("delete")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("gomall:metrics_before_row", p.before); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("gomall:metrics_after_row", p.after("row")); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("gomall:metrics_before_raw", p.before); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("gomall:metrics_after_raw", p.after("raw"))
+}
+
+func (gormMetricsPlugin) before(db *gorm.DB) {
+	db.InstanceSet(gormMetricsStartKey, time.Now())
+}
+
+func (gormMetricsPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(gormMetricsStartKey)
+		if !ok {
+			return
+		}
+		start, _ := val.(time.Time)
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		metrics.SQLQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			metrics.SQLQueryErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/dal/model"
+	"github/lhh-gh/go-mall/logic/do"
+	"gorm.io/gorm"
+)
+
+type OpenAppDao struct {
+	ctx context.Context
+}
+
+func NewOpenAppDao(ctx context.Context) *OpenAppDao {
+	return &OpenAppDao{ctx: ctx}
+}
+
+// GetOpenAppByKey 按AppKey查询应用, 应用不存在时返回 nil
+func (oad *OpenAppDao) GetOpenAppByKey(appKey string) (*model.OpenApp, error) {
+	openApp := new(model.OpenApp)
+	err := dbFrom(oad.ctx).Where("app_key = ?", appKey).First(openApp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return openApp, err
+}
+
+func (oad *OpenAppDao) CreateOpenApp(openApp *do.OpenApp) (*model.OpenApp, error) {
+	openAppModel := new(model.OpenApp)
+	err := util.CopyProperties(openAppModel, openApp)
+	if err != nil {
+		return nil, err
+	}
+	err = dbMasterFrom(oad.ctx).Create(openAppModel).Error
+	return openAppModel, err
+}
+
+// UpdateOpenAppStatus 更新应用的状态, 返回是否有应用被更新
+func (oad *OpenAppDao) UpdateOpenAppStatus(appKey string, status int) (bool, error) {
+	result := dbMasterFrom(oad.ctx).Model(&model.OpenApp{}).
+		Where("app_key = ?", appKey).
+		Update("status", status)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,98 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github/lhh-gh/go-mall/dal/model"
+	"gorm.io/gorm"
+)
+
+type RbacDao struct {
+	ctx context.Context
+}
+
+func NewRbacDao(ctx context.Context) *RbacDao {
+	return &RbacDao{ctx: ctx}
+}
+
+// GetUserPermissionCodes 查询用户通过角色获得的所有权限标识
+func (rd *RbacDao) GetUserPermissionCodes(userId int64) (codes []string, err error) {
+	err = dbFrom(rd.ctx).Model(&model.Permission{}).
+		Distinct("permissions.code").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.is_del = 0").
+		Joins("JOIN user_role_bindings ON user_role_bindings.role_id = roles.id").
+		Where("user_role_bindings.user_id = ?", userId).
+		Pluck("permissions.code", &codes).Error
+
+	return codes, err
+}
+
+// GetUserRoles 查询用户绑定的角色
+func (rd *RbacDao) GetUserRoles(userId int64) (roles []*model.Role, err error) {
+	err = dbFrom(rd.ctx).
+		Joins("JOIN user_role_bindings ON user_role_bindings.role_id = roles.id").
+		Where("user_role_bindings.user_id = ?", userId).
+		Find(&roles).Error
+
+	return roles, err
+}
+
+// GetRoleByCode 按标识查询角色, 角色不存在时返回 nil
+func (rd *RbacDao) GetRoleByCode(code string) (*model.Role, error) {
+	role := new(model.Role)
+	err := dbFrom(rd.ctx).Where("code = ?", code).First(role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return role, err
+}
+
+// GetPermissionByCode 按标识查询权限, 权限不存在时返回 nil
+func (rd *RbacDao) GetPermissionByCode(code string) (*model.Permission, error) {
+	permission := new(model.Permission)
+	err := dbFrom(rd.ctx).Where("code = ?", code).First(permission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return permission, err
+}
+
+// GetRoleUserIds 查询绑定了角色的所有用户
+func (rd *RbacDao) GetRoleUserIds(roleId int64) (userIds []int64, err error) {
+	err = dbFrom(rd.ctx).Model(&model.UserRoleBinding{}).
+		Where("role_id = ?", roleId).
+		Pluck("user_id", &userIds).Error
+
+	return userIds, err
+}
+
+// CreateUserRoleBinding 为用户绑定角色, 已经绑定过时不重复绑定
+func (rd *RbacDao) CreateUserRoleBinding(userId, roleId int64) error {
+	binding := &model.UserRoleBinding{UserId: userId, RoleId: roleId}
+	return dbMasterFrom(rd.ctx).
+		Where("user_id = ? AND role_id = ?", userId, roleId).
+		FirstOrCreate(binding).Error
+}
+
+// DeleteUserRoleBinding 解除用户与角色的绑定
+func (rd *RbacDao) DeleteUserRoleBinding(userId, roleId int64) error {
+	return dbMasterFrom(rd.ctx).
+		Where("user_id = ? AND role_id = ?", userId, roleId).
+		Delete(&model.UserRoleBinding{}).Error
+}
+
+// CreateRolePermission 为角色授予权限, 已经授予过时不重复授予
+func (rd *RbacDao) CreateRolePermission(roleId, permissionId int64) error {
+	rolePermission := &model.RolePermission{RoleId: roleId, PermissionId: permissionId}
+	return dbMasterFrom(rd.ctx).
+		Where("role_id = ? AND permission_id = ?", roleId, permissionId).
+		FirstOrCreate(rolePermission).Error
+}
+
+// DeleteRolePermission 收回角色的权限
+func (rd *RbacDao) DeleteRolePermission(roleId, permissionId int64) error {
+	return dbMasterFrom(rd.ctx).
+		Where("role_id = ? AND permission_id = ?", roleId, permissionId).
+		Delete(&model.RolePermission{}).Error
+}
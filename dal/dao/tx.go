@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"github/lhh-gh/go-mall/comon/logger"
+	"gorm.io/gorm"
+)
+
+// 通过 ctx 传递事务: WithTx 把事务放到 ctx 中, 用这个 ctx 创建的DAO执行的SQL都在事务中
+//
+//	err := dao.WithTx(ctx, func(ctx context.Context) error {
+//		orderDao := dao.NewDemoDao(ctx)
+//		...
+//		dao.AfterCommit(ctx, func(ctx context.Context) { 删除缓存、发送事件 })
+//		return nil
+//	})
+//
+//   - fn 返回错误或者 panic 时回滚事务, panic 在回滚后继续向上抛出
+//   - 在事务中再调用 WithTx 时使用保存点, 内层的 fn 返回错误只回滚到保存点, 外层可以决定是否继续
+//   - 事务不能在多个 goroutine 中同时使用
+
+type txKey struct{}
+
+type txContext struct {
+	tx         *gorm.DB
+	afterHooks []func(ctx context.Context)
+}
+
+// WithTx 在事务中执行 fn, 事务总是使用主库
+func WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	parent, nested := ctx.Value(txKey{}).(*txContext)
+	db := DB()
+	if nested {
+		db = parent.tx
+	}
+	current := new(txContext)
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 已经在事务中时 gorm 使用保存点
+		current.tx = tx
+		return fn(context.WithValue(ctx, txKey{}, current))
+	})
+	if err != nil {
+		return err
+	}
+	if nested {
+		// 外层事务提交后才执行
+		parent.afterHooks = append(parent.afterHooks, current.afterHooks...)
+		return nil
+	}
+	runAfterCommitHooks(ctx, current.afterHooks)
+	return nil
+}
+
+// AfterCommit 注册在事务提交后执行的函数, 用于删除缓存、发送事件这类不能回滚的操作
+// 事务回滚或者回滚到注册时所在的保存点时不执行, ctx 不在事务中时立即执行
+func AfterCommit(ctx context.Context, hook func(ctx context.Context)) {
+	if current, ok := ctx.Value(txKey{}).(*txContext); ok {
+		current.afterHooks = append(current.afterHooks, hook)
+		return
+	}
+	runAfterCommitHooks(ctx, []func(ctx context.Context){hook})
+}
+
+// InTx 判断 ctx 是否在事务中
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txContext)
+	return ok
+}
+
+// runAfterCommitHooks 事务已经提交, 一个函数出错不影响其他函数执行
+func runAfterCommitHooks(ctx context.Context, hooks []func(ctx context.Context)) {
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.New(ctx).Error("after commit hook panic", "err", fmt.Sprint(r))
+				}
+			}()
+			hook(ctx)
+		}()
+	}
+}
+
+// dbFrom 返回执行SQL的实例, ctx 在事务中时返回事务
+func dbFrom(ctx context.Context) *gorm.DB {
+	if current, ok := ctx.Value(txKey{}).(*txContext); ok {
+		return current.tx.WithContext(ctx)
+	}
+	return DB().WithContext(ctx)
+}
+
+// dbMasterFrom 返回强制使用主库的实例, ctx 在事务中时返回事务
+func dbMasterFrom(ctx context.Context) *gorm.DB {
+	if current, ok := ctx.Value(txKey{}).(*txContext); ok {
+		return current.tx.WithContext(ctx)
+	}
+	return DBMaster().WithContext(ctx)
+}
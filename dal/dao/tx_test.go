@@ -0,0 +1,225 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type txTestItem struct {
+	Id   int64
+	Name string
+}
+
+func TestMain(m *testing.M) {
+	// 事务和保存点的行为用 SQLite 测试, 不需要连接MySQL
+	dir, err := os.MkdirTemp("", "dao-tx-test")
+	if err != nil {
+		panic(err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "tx.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		panic(err)
+	}
+	if err = db.AutoMigrate(&txTestItem{}); err != nil {
+		panic(err)
+	}
+	_Db = db
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func resetTxTestItems(t *testing.T) {
+	t.Helper()
+	if err := DB().Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&txTestItem{}).Error; err != nil {
+		t.Fatalf("reset items error: %v", err)
+	}
+}
+
+func txTestItemNames(t *testing.T) []string {
+	t.Helper()
+	names := make([]string, 0)
+	if err := DB().Model(&txTestItem{}).Order("id").Pluck("name", &names).Error; err != nil {
+		t.Fatalf("query items error: %v", err)
+	}
+	return names
+}
+
+func createTxTestItem(ctx context.Context, name string) error {
+	return dbFrom(ctx).Create(&txTestItem{Name: name}).Error
+}
+
+func TestWithTx(t *testing.T) {
+	errRollback := errors.New("rollback")
+	tests := []struct {
+		name      string
+		run       func(ctx context.Context, hooks *[]string) error
+		wantErr   error
+		wantNames []string
+		wantHooks []string
+	}{
+		{
+			name: "commit runs hooks after commit",
+			run: func(ctx context.Context, hooks *[]string) error {
+				return WithTx(ctx, func(ctx context.Context) error {
+					AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "outer") })
+					return createTxTestItem(ctx, "a")
+				})
+			},
+			wantNames: []string{"a"},
+			wantHooks: []string{"outer"},
+		},
+		{
+			name: "error rolls back and skips hooks",
+			run: func(ctx context.Context, hooks *[]string) error {
+				return WithTx(ctx, func(ctx context.Context) error {
+					AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "outer") })
+					if err := createTxTestItem(ctx, "a"); err != nil {
+						return err
+					}
+					return errRollback
+				})
+			},
+			wantErr:   errRollback,
+			wantNames: []string{},
+		},
+		{
+			name: "failed nested tx rolls back to savepoint only",
+			run: func(ctx context.Context, hooks *[]string) error {
+				return WithTx(ctx, func(ctx context.Context) error {
+					if err := createTxTestItem(ctx, "a"); err != nil {
+						return err
+					}
+					innerErr := WithTx(ctx, func(ctx context.Context) error {
+						AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "inner") })
+						if err := createTxTestItem(ctx, "b"); err != nil {
+							return err
+						}
+						return errRollback
+					})
+					if !errors.Is(innerErr, errRollback) {
+						return errors.New("inner error not returned")
+					}
+					AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "outer") })
+					return createTxTestItem(ctx, "c")
+				})
+			},
+			wantNames: []string{"a", "c"},
+			wantHooks: []string{"outer"},
+		},
+		{
+			name: "nested hooks wait for the outer commit",
+			run: func(ctx context.Context, hooks *[]string) error {
+				return WithTx(ctx, func(ctx context.Context) error {
+					err := WithTx(ctx, func(ctx context.Context) error {
+						AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "inner") })
+						return createTxTestItem(ctx, "a")
+					})
+					if err != nil {
+						return err
+					}
+					if len(*hooks) != 0 {
+						return errors.New("inner hook ran before the outer commit")
+					}
+					AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "outer") })
+					return nil
+				})
+			},
+			wantNames: []string{"a"},
+			wantHooks: []string{"inner", "outer"},
+		},
+		{
+			name: "outer rollback discards committed savepoint and its hooks",
+			run: func(ctx context.Context, hooks *[]string) error {
+				return WithTx(ctx, func(ctx context.Context) error {
+					err := WithTx(ctx, func(ctx context.Context) error {
+						AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "inner") })
+						return createTxTestItem(ctx, "a")
+					})
+					if err != nil {
+						return err
+					}
+					return errRollback
+				})
+			},
+			wantErr:   errRollback,
+			wantNames: []string{},
+		},
+		{
+			name: "panicking hook does not stop other hooks",
+			run: func(ctx context.Context, hooks *[]string) error {
+				return WithTx(ctx, func(ctx context.Context) error {
+					AfterCommit(ctx, func(context.Context) { panic("hook failed") })
+					AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "second") })
+					return createTxTestItem(ctx, "a")
+				})
+			},
+			wantNames: []string{"a"},
+			wantHooks: []string{"second"},
+		},
+		{
+			name: "hook outside tx runs immediately",
+			run: func(ctx context.Context, hooks *[]string) error {
+				AfterCommit(ctx, func(context.Context) { *hooks = append(*hooks, "now") })
+				return nil
+			},
+			wantNames: []string{},
+			wantHooks: []string{"now"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTxTestItems(t)
+			hooks := make([]string, 0)
+			err := tt.run(context.Background(), &hooks)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if names := txTestItemNames(t); !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("items = %v, want %v", names, tt.wantNames)
+			}
+			if tt.wantHooks == nil {
+				tt.wantHooks = []string{}
+			}
+			if !reflect.DeepEqual(hooks, tt.wantHooks) {
+				t.Errorf("hooks = %v, want %v", hooks, tt.wantHooks)
+			}
+		})
+	}
+}
+
+func TestWithTxPanicRollsBack(t *testing.T) {
+	resetTxTestItems(t)
+	hookRan := false
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("WithTx() did not re-panic")
+			}
+		}()
+		WithTx(context.Background(), func(ctx context.Context) error {
+			AfterCommit(ctx, func(context.Context) { hookRan = true })
+			if err := createTxTestItem(ctx, "a"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if names := txTestItemNames(t); len(names) != 0 {
+		t.Errorf("items = %v, want rolled back", names)
+	}
+	if hookRan {
+		t.Error("after commit hook ran after panic")
+	}
+	if InTx(context.Background()) {
+		t.Error("InTx(background) = true")
+	}
+}
@@ -17,6 +17,7 @@ type DemoOrder struct {
 	OrderNo   string                `gorm:"column:order_no;type:varchar(32)" json:"order_no"` //订单号
 	State     int8                  `gorm:"column:state;default:1" json:"state"`              //1-待支付，2-支付成功，3-支付失败
 	PaidAt    time.Time             `gorm:"column:paid_at;default:\"1970-01-01 00:00:00\"" json:"paid_at"`
+	OpenAppId int64                 `gorm:"column:open_app_id" json:"open_app_id"` //通过开放接口下单的合作方应用ID, 站内下单为0
 	IsDel     soft_delete.DeletedAt `gorm:"softDelete:flag"`
 	CreatedAt time.Time             `gorm:"column:created_at" json:"created_at"` //创建时间
 	UpdatedAt time.Time             `gorm:"column:updated_at" json:"updated_at"` //更新时间
@@ -25,3 +26,19 @@ type DemoOrder struct {
 func (DemoOrder) TableName() string {
 	return "demo_orders"
 }
+
+// DemoOrderGoodsSnapshot 下单时的订单商品快照, 和订单在同一个事务中写入
+type DemoOrderGoodsSnapshot struct {
+	Id           int64     `gorm:"column:id;primary_key" json:"id"`                  //自增ID
+	OrderId      int64     `gorm:"column:order_id" json:"order_id"`                  //订单ID
+	OrderNo      string    `gorm:"column:order_no;type:varchar(32)" json:"order_no"` //订单号
+	OrderGoodsId int64     `gorm:"column:order_goods_id" json:"order_goods_id"`      //商品ID
+	UserId       int64     `gorm:"column:user_id" json:"user_id"`                    //用户ID
+	BillMoney    int64     `gorm:"column:bill_money" json:"bill_money"`              //下单时的金额（分）
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`              //创建时间
+	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updated_at"`              //更新时间
+}
+
+func (DemoOrderGoodsSnapshot) TableName() string {
+	return "demo_order_goods_snapshots"
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+	"time"
+)
+
+// OpenApp 开放平台的合作方应用
+type OpenApp struct {
+	Id        int64                 `gorm:"column:id;primary_key" json:"id"`                //自增ID
+	AppKey    string                `gorm:"column:app_key;type:varchar(64)" json:"app_key"` //应用标识
+	AppSecret string                `gorm:"column:app_secret;type:varchar(128)" json:"-"`   //签名密钥
+	Name      string                `gorm:"column:name;type:varchar(64)" json:"name"`       //应用名称
+	Status    int                   `gorm:"column:status;type:tinyint" json:"status"`       //状态 1-启用 0-停用
+	IsDel     soft_delete.DeletedAt `gorm:"softDelete:flag"`
+	CreatedAt time.Time             `gorm:"column:created_at" json:"created_at"` //创建时间
+	UpdatedAt time.Time             `gorm:"column:updated_at" json:"updated_at"` //更新时间
+}
+
+func (OpenApp) TableName() string {
+	return "open_apps"
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"gorm.io/plugin/soft_delete"
+	"time"
+)
+
+// Role 角色
+type Role struct {
+	Id          int64                 `gorm:"column:id;primary_key" json:"id"`                         //自增ID
+	Code        string                `gorm:"column:code;type:varchar(64)" json:"code"`                //角色标识, 比如 admin
+	Name        string                `gorm:"column:name;type:varchar(64)" json:"name"`                //角色名称
+	Description string                `gorm:"column:description;type:varchar(255)" json:"description"` //角色描述
+	IsDel       soft_delete.DeletedAt `gorm:"softDelete:flag"`
+	CreatedAt   time.Time             `gorm:"column:created_at" json:"created_at"` //创建时间
+	UpdatedAt   time.Time             `gorm:"column:updated_at" json:"updated_at"` //更新时间
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Permission 权限, Code 的格式为 资源:操作, 比如 order:refund
+type Permission struct {
+	Id          int64                 `gorm:"column:id;primary_key" json:"id"`                         //自增ID
+	Code        string                `gorm:"column:code;type:varchar(64)" json:"code"`                //权限标识
+	Name        string                `gorm:"column:name;type:varchar(64)" json:"name"`                //权限名称
+	Description string                `gorm:"column:description;type:varchar(255)" json:"description"` //权限描述
+	IsDel       soft_delete.DeletedAt `gorm:"softDelete:flag"`
+	CreatedAt   time.Time             `gorm:"column:created_at" json:"created_at"` //创建时间
+	UpdatedAt   time.Time             `gorm:"column:updated_at" json:"updated_at"` //更新时间
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// RolePermission 角色拥有的权限
+type RolePermission struct {
+	Id           int64     `gorm:"column:id;primary_key" json:"id"`           //自增ID
+	RoleId       int64     `gorm:"column:role_id" json:"role_id"`             //角色ID
+	PermissionId int64     `gorm:"column:permission_id" json:"permission_id"` //权限ID
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`       //创建时间
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// UserRoleBinding 用户绑定的角色
+type UserRoleBinding struct {
+	Id        int64     `gorm:"column:id;primary_key" json:"id"`     //自增ID
+	UserId    int64     `gorm:"column:user_id" json:"user_id"`       //用户ID
+	RoleId    int64     `gorm:"column:role_id" json:"role_id"`       //角色ID
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"` //创建时间
+}
+
+func (UserRoleBinding) TableName() string {
+	return "user_role_bindings"
+}
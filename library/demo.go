@@ -2,14 +2,15 @@ package library
 
 import (
 	"context"
-	"encoding/json"
 	"github/lhh-gh/go-mall/api/request"
 	"github/lhh-gh/go-mall/comon/logger"
 	"github/lhh-gh/go-mall/comon/util/httptool"
+	"net/http"
 )
 
 type DemoLib struct {
-	ctx context.Context
+	ctx    context.Context
+	client *http.Client
 }
 
 // NewDemoLib 创建时上层通过ctx 把 gin.Ctx传递过来
@@ -17,6 +18,12 @@ func NewDemoLib(ctx context.Context) *DemoLib {
 	return &DemoLib{ctx: ctx}
 }
 
+// WithHttpClient 使用指定的 Client 发起请求, 测试时可以注入回放 fixture 的 Client
+func (lib *DemoLib) WithHttpClient(client *http.Client) *DemoLib {
+	lib.client = client
+	return lib
+}
+
 type OrderCreateResult struct {
 	UserId    int64  `json:"user_id"`
 	BillMoney int64  `json:"bill_money"`
@@ -35,18 +42,17 @@ func (lib *DemoLib) TestPostCreateOrder() (*OrderCreateResult, error) {
 		BillMoney:    20,
 		OrderGoodsId: 1111110,
 	}
-	jsonReq, _ := json.Marshal(data)
-	httCode, respBody, err := httptool.Post(lib.ctx, "http://localhost:8080/building/create-demo-order", jsonReq)
-	logger.New(lib.ctx).Info("create-demo-order api response ", "code", httCode, "data", respBody, "err", err)
-
+	result, resp, err := httptool.PostEnvelope[*request.DemoOrderCreate, OrderCreateResult](
+		lib.ctx, "/building/create-demo-order", data,
+		httptool.WithUpstream("demo"),
+		httptool.WithClient(lib.client),
+		// 透传当前用户的Token
+		httptool.WithInterceptors(httptool.ForwardAuthInterceptor()),
+	)
 	if err != nil {
+		logger.New(lib.ctx).Error("create-demo-order api error", "err", err)
 		return nil, err
 	}
-
-	reply := &struct {
-		Code int `json:"code"`
-		Data *OrderCreateResult
-	}{}
-	json.Unmarshal(respBody, reply)
-	return reply.Data, nil
+	logger.New(lib.ctx).Info("create-demo-order api response ", "code", resp.StatusCode, "data", result)
+	return result, nil
 }
@@ -0,0 +1,221 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"github.com/oschwald/geoip2-golang"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/config"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IP情报: 查询IP所属的国家
+// 优先查本地的离线 GeoIP 库(MMDB 文件), 查不到时回退到 ipwhois 的HTTP接口, 查询结果在进程内缓存
+// 查不到或者查询失败的结果也缓存一小段时间, 避免同一个IP的每个请求都去调用 ipwhois
+
+const (
+	defaultIpIntelCacheTTL         = 24 * time.Hour
+	defaultIpIntelNegativeCacheTTL = time.Minute
+	defaultIpIntelCacheSize        = 10000
+
+	IpSourceMMDB  = "mmdb"
+	IpSourceWhois = "ipwhois"
+)
+
+// ErrIpNotFound IP在数据源中查不到
+var ErrIpNotFound = errors.New("ip not found")
+
+// IpLocation IP的地理位置信息
+type IpLocation struct {
+	Ip          string `json:"ip"`
+	CountryCode string `json:"country_code"` // ISO 3166-1 两位国家代码, 比如 CN
+	Country     string `json:"country"`
+	Source      string `json:"source"` // 数据来源 mmdb 或 ipwhois
+}
+
+// IpLocator IP地理位置数据源
+type IpLocator interface {
+	Locate(ctx context.Context, ip net.IP) (*IpLocation, error)
+}
+
+type IpIntelLib struct {
+	ctx context.Context
+}
+
+func NewIpIntelLib(ctx context.Context) *IpIntelLib {
+	return &IpIntelLib{ctx: ctx}
+}
+
+// Lookup 查询IP所属的国家, 离线库查不到时回退到 ipwhois
+func (lib *IpIntelLib) Lookup(ip string) (*IpLocation, error) {
+	return lib.lookup(ip, true)
+}
+
+// LookupOffline 只查缓存和离线库, 不发起HTTP请求, 用于访问日志这类不能增加请求耗时的场景
+func (lib *IpIntelLib) LookupOffline(ip string) (*IpLocation, error) {
+	return lib.lookup(ip, false)
+}
+
+func (lib *IpIntelLib) lookup(ip string, withFallback bool) (*IpLocation, error) {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return nil, errors.New("invalid ip: " + ip)
+	}
+	if parsedIp.IsLoopback() || parsedIp.IsPrivate() || parsedIp.IsUnspecified() {
+		// 内网地址没有国家信息
+		return nil, ErrIpNotFound
+	}
+	if entry, ok := ipIntelCache.get(ip); ok && (entry.location != nil || entry.withFallback || !withFallback) {
+		// 只查了离线库的失败结果不能用于需要回退到 ipwhois 的查询
+		return entry.location, entry.err
+	}
+	locators := getIpLocators(withFallback)
+	var lastErr error = ErrIpNotFound
+	for _, locator := range locators {
+		location, err := locator.Locate(lib.ctx, parsedIp)
+		if err == nil {
+			ipIntelCache.set(ip, ipLocationCacheEntry{location: location})
+			return location, nil
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			// 请求被取消不是IP的问题, 不缓存
+			return nil, err
+		}
+		if !errors.Is(err, ErrIpNotFound) {
+			logger.New(lib.ctx).Warn("ip_locate_error", "ip", ip, "err", err)
+		}
+		lastErr = err
+	}
+	ipIntelCache.set(ip, ipLocationCacheEntry{err: lastErr, withFallback: withFallback})
+
+	return nil, lastErr
+}
+
+var (
+	mmdbOnce    sync.Once
+	mmdbLocator IpLocator
+)
+
+// getIpLocators 按配置组装数据源, MMDB 文件在第一次查询时打开, 打开失败时只使用 ipwhois
+func getIpLocators(withFallback bool) []IpLocator {
+	mmdbOnce.Do(func() {
+		if config.IpIntel == nil || config.IpIntel.MMDBPath == "" {
+			return
+		}
+		reader, err := geoip2.Open(config.IpIntel.MMDBPath)
+		if err != nil {
+			logger.New(context.Background()).Error("open mmdb error", "path", config.IpIntel.MMDBPath, "err", err)
+			return
+		}
+		mmdbLocator = &mmdbIpLocator{reader: reader}
+	})
+	locators := make([]IpLocator, 0, 2)
+	if mmdbLocator != nil {
+		locators = append(locators, mmdbLocator)
+	}
+	if withFallback && (config.IpIntel == nil || config.IpIntel.WhoisFallback) {
+		locators = append(locators, whoisIpLocator{})
+	}
+	return locators
+}
+
+// mmdbIpLocator 查询离线的 GeoIP 库, 支持 GeoLite2/GeoIP2 的 Country 和 City 库
+type mmdbIpLocator struct {
+	reader *geoip2.Reader
+}
+
+func (l *mmdbIpLocator) Locate(ctx context.Context, ip net.IP) (*IpLocation, error) {
+	record, err := l.reader.Country(ip)
+	if err != nil {
+		return nil, err
+	}
+	if record.Country.IsoCode == "" {
+		return nil, ErrIpNotFound
+	}
+	return &IpLocation{
+		Ip:          ip.String(),
+		CountryCode: record.Country.IsoCode,
+		Country:     record.Country.Names["en"],
+		Source:      IpSourceMMDB,
+	}, nil
+}
+
+// whoisIpLocator 通过 ipwhois 的HTTP接口查询
+type whoisIpLocator struct{}
+
+func (whoisIpLocator) Locate(ctx context.Context, ip net.IP) (*IpLocation, error) {
+	detail, err := NewWhoisLib(ctx).GetIpDetail(ip.String())
+	if err != nil {
+		return nil, err
+	}
+	if detail.CountryCode == "" {
+		return nil, ErrIpNotFound
+	}
+	return &IpLocation{
+		Ip:          detail.Ip,
+		CountryCode: strings.ToUpper(detail.CountryCode),
+		Country:     detail.Country,
+		Source:      IpSourceWhois,
+	}, nil
+}
+
+// ipLocationCache 进程内的查询结果缓存, 超过容量时先清理过期的结果, 仍然超过时清空
+type ipLocationCache struct {
+	mu      sync.RWMutex
+	entries map[string]ipLocationCacheEntry
+}
+
+type ipLocationCacheEntry struct {
+	location     *IpLocation
+	err          error // 查不到或者查询失败时的错误, 这时 location 为 nil
+	withFallback bool  // 查询失败时是否已经回退到 ipwhois 查询过
+	expiresAt    time.Time
+}
+
+var ipIntelCache = &ipLocationCache{entries: make(map[string]ipLocationCacheEntry)}
+
+func (c *ipLocationCache) get(ip string) (ipLocationCacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[ip]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return ipLocationCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *ipLocationCache) set(ip string, entry ipLocationCacheEntry) {
+	ttl, negativeTTL, size := defaultIpIntelCacheTTL, defaultIpIntelNegativeCacheTTL, defaultIpIntelCacheSize
+	if config.IpIntel != nil {
+		if config.IpIntel.CacheTTL > 0 {
+			ttl = config.IpIntel.CacheTTL
+		}
+		if config.IpIntel.NegativeCacheTTL > 0 {
+			negativeTTL = config.IpIntel.NegativeCacheTTL
+		}
+		if config.IpIntel.CacheSize > 0 {
+			size = config.IpIntel.CacheSize
+		}
+	}
+	if entry.location == nil {
+		ttl = negativeTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= size {
+		for key, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= size {
+			c.entries = make(map[string]ipLocationCacheEntry)
+		}
+	}
+	entry.expiresAt = now.Add(ttl)
+	c.entries[ip] = entry
+}
@@ -2,22 +2,31 @@ package library
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"github/lhh-gh/go-mall/comon/logger"
 	"github/lhh-gh/go-mall/comon/util/httptool"
+	"net/http"
+	"net/url"
 )
 
 // 对接 ipwhois.io 的Lib
 // Documentation: https://ipwhois.io/documentation
 
 type WhoisLib struct {
-	ctx context.Context
+	ctx    context.Context
+	client *http.Client
 }
 
 func NewWhoisLib(ctx context.Context) *WhoisLib {
 	return &WhoisLib{ctx: ctx}
 }
 
+// WithHttpClient 使用指定的 Client 发起请求, 测试时可以注入回放 fixture 的 Client
+func (whois *WhoisLib) WithHttpClient(client *http.Client) *WhoisLib {
+	whois.client = client
+	return whois
+}
+
 type WhoisIpDetail struct {
 	Ip            string  `json:"ip"`
 	Success       bool    `json:"success"`
@@ -36,23 +45,31 @@ type WhoisIpDetail struct {
 	CallingCode   string  `json:"calling_code"`
 	Capital       string  `json:"capital"`
 	Borders       string  `json:"borders"`
+	Message       string  `json:"message"` // 查询失败的原因
 }
 
-func (whois *WhoisLib) GetHostIpDetail() (*WhoisIpDetail, error) {
+// GetIpDetail 查询IP的地理位置等信息, ip 为空时查询本机的出口IP
+func (whois *WhoisLib) GetIpDetail(ip string) (*WhoisIpDetail, error) {
 	log := logger.New(whois.ctx)
 
-	httpStatusCode, respBody, err := httptool.Get(
-		whois.ctx, "https://www.baidu.com",
-		httptool.WithHeaders(map[string]string{
-			"User-Agent": "curl/7.77.0",
-		}),
+	reply, resp, err := httptool.GetJSON[WhoisIpDetail](
+		whois.ctx, "/"+url.PathEscape(ip),
+		httptool.WithUpstream("ipwhois"),
+		httptool.WithRetry(httptool.RetryPolicy{MaxAttempts: 3}),
+		httptool.WithClient(whois.client),
 	)
 	if err != nil {
-		log.Error("whois request error", "err", err, "httpStatusCode", httpStatusCode)
+		var respBody []byte
+		if resp != nil {
+			respBody = resp.Body
+		}
+		log.Error("whois request error", "err", err, "body", string(respBody))
 		return nil, err
 	}
-	reply := new(WhoisIpDetail)
-	json.Unmarshal(respBody, reply)
+	if !reply.Success {
+		// 查询失败时 ipwhois 同样返回200, 失败原因在 message 字段中
+		return nil, fmt.Errorf("whois lookup %s failed: %s", ip, reply.Message)
+	}
 
 	return reply, nil
 }
@@ -0,0 +1,49 @@
+package library
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/comon/util/httptool"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newWhoisReplayLib 创建回放 testdata/whois.json 的 WhoisLib, 设置 HTTPTOOL_RECORD 环境变量后运行测试可以重新录制
+func newWhoisReplayLib(t *testing.T) *WhoisLib {
+	t.Helper()
+	rt, err := httptool.NewCassette("testdata/whois.json")
+	if err != nil {
+		t.Fatalf("load whois fixture error: %v", err)
+	}
+	return NewWhoisLib(context.Background()).WithHttpClient(&http.Client{Transport: rt})
+}
+
+func TestWhoisLibGetIpDetail(t *testing.T) {
+	tests := []struct {
+		name        string
+		ip          string
+		wantCountry string
+		wantErr     string
+	}{
+		{name: "public ip", ip: "8.8.8.8", wantCountry: "US"},
+		{name: "lookup failed", ip: "10.0.0.1", wantErr: "Reserved range"},
+		{name: "no fixture", ip: "1.1.1.1", wantErr: httptool.ErrNoFixture.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, err := newWhoisReplayLib(t).GetIpDetail(tt.ip)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetIpDetail(%s) error = %v, want error containing %q", tt.ip, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetIpDetail(%s) unexpected error: %v", tt.ip, err)
+			}
+			if detail.Ip != tt.ip || detail.CountryCode != tt.wantCountry {
+				t.Errorf("GetIpDetail(%s) = %+v, want country %s", tt.ip, detail, tt.wantCountry)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package appservice
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/api/reply"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/logic/do"
+	"github/lhh-gh/go-mall/logic/domainservice"
+)
+
+type AuthAppSvc struct {
+	ctx           context.Context
+	authDomainSvc *domainservice.AuthDomainSvc
+}
+
+func NewAuthAppSvc(ctx context.Context) *AuthAppSvc {
+	return &AuthAppSvc{
+		ctx:           ctx,
+		authDomainSvc: domainservice.NewAuthDomainSvc(ctx),
+	}
+}
+
+// IssueToken 为用户签发Token
+func (aas *AuthAppSvc) IssueToken(userId int64) (*reply.TokenReply, error) {
+	tokenInfo, err := aas.authDomainSvc.GenAuthToken(userId)
+	if err != nil {
+		return nil, err
+	}
+	return aas.toTokenReply(tokenInfo)
+}
+
+// RefreshToken 刷新用户的Token
+func (aas *AuthAppSvc) RefreshToken(tokenRequest *request.TokenRefresh) (*reply.TokenReply, error) {
+	tokenInfo, err := aas.authDomainSvc.RefreshAuthToken(tokenRequest.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return aas.toTokenReply(tokenInfo)
+}
+
+// RevokeToken 吊销用户的Token
+func (aas *AuthAppSvc) RevokeToken(tokenRequest *request.TokenRefresh) error {
+	return aas.authDomainSvc.RevokeAuthToken(tokenRequest.RefreshToken)
+}
+
+func (aas *AuthAppSvc) toTokenReply(tokenInfo *do.TokenInfo) (*reply.TokenReply, error) {
+	tokenReply := new(reply.TokenReply)
+	err := util.CopyProperties(tokenReply, tokenInfo)
+	if err != nil {
+		return nil, errcode.Wrap("tokenInfo转换成tokenReply失败", err)
+	}
+	return tokenReply, nil
+}
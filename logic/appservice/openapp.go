@@ -0,0 +1,39 @@
+package appservice
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/api/reply"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/logic/domainservice"
+)
+
+type OpenAppAppSvc struct {
+	ctx              context.Context
+	openAppDomainSvc *domainservice.OpenAppDomainSvc
+}
+
+func NewOpenAppAppSvc(ctx context.Context) *OpenAppAppSvc {
+	return &OpenAppAppSvc{
+		ctx:              ctx,
+		openAppDomainSvc: domainservice.NewOpenAppDomainSvc(ctx),
+	}
+}
+
+// CreateOpenApp 创建应用, AppSecret 只在创建时返回一次
+func (oas *OpenAppAppSvc) CreateOpenApp(createRequest *request.OpenAppCreate) (*reply.OpenApp, error) {
+	openApp, err := oas.openAppDomainSvc.CreateOpenApp(createRequest.Name)
+	if err != nil {
+		return nil, err
+	}
+	replyOpenApp := new(reply.OpenApp)
+	if err = util.CopyProperties(replyOpenApp, openApp); err != nil {
+		return nil, errcode.Wrap("openAppDo转换成replyOpenApp失败", err)
+	}
+	return replyOpenApp, nil
+}
+
+func (oas *OpenAppAppSvc) UpdateOpenAppStatus(statusRequest *request.OpenAppStatus) error {
+	return oas.openAppDomainSvc.UpdateOpenAppStatus(statusRequest.AppKey, *statusRequest.Enabled)
+}
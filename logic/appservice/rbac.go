@@ -0,0 +1,51 @@
+package appservice
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/api/reply"
+	"github/lhh-gh/go-mall/api/request"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/logic/domainservice"
+)
+
+type RbacAppSvc struct {
+	ctx           context.Context
+	rbacDomainSvc *domainservice.RbacDomainSvc
+}
+
+func NewRbacAppSvc(ctx context.Context) *RbacAppSvc {
+	return &RbacAppSvc{
+		ctx:           ctx,
+		rbacDomainSvc: domainservice.NewRbacDomainSvc(ctx),
+	}
+}
+
+func (ras *RbacAppSvc) GetUserRoles(userId int64) ([]*reply.Role, error) {
+	roles, err := ras.rbacDomainSvc.GetUserRoles(userId)
+	if err != nil {
+		return nil, err
+	}
+	replyRoles := make([]*reply.Role, 0, len(roles))
+	err = util.CopyProperties(&replyRoles, roles)
+	if err != nil {
+		return nil, errcode.Wrap("roleDo转换成replyRole失败", err)
+	}
+	return replyRoles, nil
+}
+
+func (ras *RbacAppSvc) BindUserRole(bindingRequest *request.RoleBinding) error {
+	return ras.rbacDomainSvc.BindUserRole(bindingRequest.UserId, bindingRequest.RoleCode)
+}
+
+func (ras *RbacAppSvc) UnbindUserRole(bindingRequest *request.RoleBinding) error {
+	return ras.rbacDomainSvc.UnbindUserRole(bindingRequest.UserId, bindingRequest.RoleCode)
+}
+
+func (ras *RbacAppSvc) GrantRolePermission(permissionRequest *request.RolePermission) error {
+	return ras.rbacDomainSvc.GrantRolePermission(permissionRequest.RoleCode, permissionRequest.PermissionCode)
+}
+
+func (ras *RbacAppSvc) RevokeRolePermission(permissionRequest *request.RolePermission) error {
+	return ras.rbacDomainSvc.RevokeRolePermission(permissionRequest.RoleCode, permissionRequest.PermissionCode)
+}
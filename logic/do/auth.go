@@ -0,0 +1,20 @@
+package do
+
+import "time"
+
+// TokenInfo 签发给用户的一组Token
+type TokenInfo struct {
+	UserId                int64     `json:"user_id"`
+	SessionId             string    `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	RefreshToken          string    `json:"refresh_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// TokenSession Refresh Token 对应的会话信息, 保存在缓存中
+type TokenSession struct {
+	UserId    int64     `json:"user_id"`
+	SessionId string    `json:"session_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
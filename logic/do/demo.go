@@ -9,13 +9,15 @@ import (
 */
 
 type DemoOrder struct {
-	Id        int64     `json:"id"`
-	UserId    int64     `json:"user_id"`
-	BillMoney int64     `json:"bill_money"`
-	OrderNo   string    `json:"order_no"`
-	State     int8      `json:"state"`
-	IsDel     uint      `json:"is_del"`
-	PaidAt    time.Time `json:"paid_at"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id           int64     `json:"id"`
+	UserId       int64     `json:"user_id"`
+	BillMoney    int64     `json:"bill_money"`
+	OrderNo      string    `json:"order_no"`
+	OrderGoodsId int64     `json:"order_goods_id"` // 下单的商品, 用于写订单商品快照
+	OpenAppId    int64     `json:"open_app_id"`    // 通过开放接口下单的合作方应用, 站内下单为0
+	State        int8      `json:"state"`
+	IsDel        uint      `json:"is_del"`
+	PaidAt       time.Time `json:"paid_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
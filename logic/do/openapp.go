@@ -0,0 +1,14 @@
+package do
+
+import "time"
+
+// OpenApp 开放平台的合作方应用
+type OpenApp struct {
+	Id        int64     `json:"id"`
+	AppKey    string    `json:"app_key"`
+	AppSecret string    `json:"-"` // 签名密钥不写入缓存, 见 OpenAppDomainSvc.GetOpenApp
+	Name      string    `json:"name"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package do
+
+import "time"
+
+// Role 角色
+type Role struct {
+	Id          int64     `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
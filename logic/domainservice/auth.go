@@ -0,0 +1,90 @@
+package domainservice
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/dal/cache"
+	"github/lhh-gh/go-mall/logic/do"
+	"time"
+)
+
+type AuthDomainSvc struct {
+	ctx context.Context
+}
+
+func NewAuthDomainSvc(ctx context.Context) *AuthDomainSvc {
+	return &AuthDomainSvc{ctx: ctx}
+}
+
+// GenAuthToken 用户登录成功后为用户开启新会话并签发Token
+func (ads *AuthDomainSvc) GenAuthToken(userId int64) (*do.TokenInfo, error) {
+	return ads.genSessionToken(userId, auth.RandomToken(16))
+}
+
+// RefreshAuthToken 用 Refresh Token 换取新的一组Token, 旧的 Refresh Token 随即失效
+// Refresh Token 不存在、已过期或已被使用过时返回 errcode.ErrToken
+func (ads *AuthDomainSvc) RefreshAuthToken(refreshToken string) (*do.TokenInfo, error) {
+	session, err := cache.GetRefreshToken(ads.ctx, refreshToken)
+	if err != nil {
+		return nil, errcode.Wrap("读取RefreshToken失败", err)
+	}
+	if session == nil {
+		return nil, errcode.ErrToken
+	}
+	deleted, err := cache.DelRefreshToken(ads.ctx, refreshToken)
+	if err != nil {
+		return nil, errcode.Wrap("删除RefreshToken失败", err)
+	}
+	if !deleted {
+		// 并发刷新时同一个 Refresh Token 已经被其他请求使用
+		return nil, errcode.ErrToken
+	}
+
+	return ads.genSessionToken(session.UserId, session.SessionId)
+}
+
+// RevokeAuthToken 吊销 Refresh Token 所在的会话, 会话中已签发的 Access Token 也随之失效
+func (ads *AuthDomainSvc) RevokeAuthToken(refreshToken string) error {
+	session, err := cache.GetRefreshToken(ads.ctx, refreshToken)
+	if err != nil {
+		return errcode.Wrap("读取RefreshToken失败", err)
+	}
+	if session == nil {
+		return errcode.ErrToken
+	}
+	if _, err = cache.DelRefreshToken(ads.ctx, refreshToken); err != nil {
+		return errcode.Wrap("删除RefreshToken失败", err)
+	}
+	if err = cache.SetSessionRevoked(ads.ctx, session.SessionId, auth.AccessTokenTTL()); err != nil {
+		return errcode.Wrap("吊销会话失败", err)
+	}
+
+	return nil
+}
+
+func (ads *AuthDomainSvc) genSessionToken(userId int64, sessionId string) (*do.TokenInfo, error) {
+	now := time.Now()
+	tokenInfo := &do.TokenInfo{
+		UserId:                userId,
+		SessionId:             sessionId,
+		RefreshToken:          auth.RandomToken(32),
+		AccessTokenExpiresAt:  now.Add(auth.AccessTokenTTL()),
+		RefreshTokenExpiresAt: now.Add(auth.RefreshTokenTTL()),
+	}
+	accessToken, _, err := auth.SignAccessToken(userId, sessionId, tokenInfo.AccessTokenExpiresAt)
+	if err != nil {
+		return nil, errcode.Wrap("签发AccessToken失败", err)
+	}
+	tokenInfo.AccessToken = accessToken
+	err = cache.SetRefreshToken(ads.ctx, tokenInfo.RefreshToken, &do.TokenSession{
+		UserId:    userId,
+		SessionId: sessionId,
+		ExpiresAt: tokenInfo.RefreshTokenExpiresAt,
+	})
+	if err != nil {
+		return nil, errcode.Wrap("保存RefreshToken失败", err)
+	}
+
+	return tokenInfo, nil
+}
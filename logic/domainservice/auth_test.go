@@ -0,0 +1,97 @@
+package domainservice
+
+import (
+	"context"
+	"errors"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/dal/cache"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRefreshAuthTokenRotation(t *testing.T) {
+	svc := NewAuthDomainSvc(context.Background())
+	login, err := svc.GenAuthToken(1)
+	if err != nil {
+		t.Fatalf("GenAuthToken() error = %v", err)
+	}
+	refreshed, err := svc.RefreshAuthToken(login.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshAuthToken() error = %v", err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken || refreshed.AccessToken == login.AccessToken {
+		t.Error("refresh did not issue new tokens")
+	}
+	// 刷新出的Token和登录时的Token属于同一个会话
+	if refreshed.UserId != 1 || refreshed.SessionId != login.SessionId {
+		t.Errorf("refreshed = user %d session %s, want user 1 session %s", refreshed.UserId, refreshed.SessionId, login.SessionId)
+	}
+	identity, err := auth.ParseAccessToken(refreshed.AccessToken)
+	if err != nil || identity.SessionId != login.SessionId {
+		t.Errorf("ParseAccessToken(refreshed) = %+v, %v, want session %s", identity, err, login.SessionId)
+	}
+
+	// 旧的 Refresh Token 用过一次后失效, 新的可以继续刷新
+	if _, err = svc.RefreshAuthToken(login.RefreshToken); !errors.Is(err, errcode.ErrToken) {
+		t.Errorf("reuse old refresh token error = %v, want ErrToken", err)
+	}
+	if _, err = svc.RefreshAuthToken(refreshed.RefreshToken); err != nil {
+		t.Errorf("RefreshAuthToken(new) error = %v", err)
+	}
+	if _, err = svc.RefreshAuthToken("unknown"); !errors.Is(err, errcode.ErrToken) {
+		t.Errorf("unknown refresh token error = %v, want ErrToken", err)
+	}
+}
+
+func TestRefreshAuthTokenConcurrentReuse(t *testing.T) {
+	svc := NewAuthDomainSvc(context.Background())
+	login, err := svc.GenAuthToken(1)
+	if err != nil {
+		t.Fatalf("GenAuthToken() error = %v", err)
+	}
+	// 同一个 Refresh Token 并发刷新, 只能有一个请求换到新Token
+	var succeeded, rejected int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.RefreshAuthToken(login.RefreshToken)
+			switch {
+			case err == nil:
+				atomic.AddInt32(&succeeded, 1)
+			case errors.Is(err, errcode.ErrToken):
+				atomic.AddInt32(&rejected, 1)
+			default:
+				t.Errorf("RefreshAuthToken() unexpected error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 || rejected != 9 {
+		t.Errorf("succeeded = %d rejected = %d, want 1 and 9", succeeded, rejected)
+	}
+}
+
+func TestRevokeAuthToken(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAuthDomainSvc(ctx)
+	login, err := svc.GenAuthToken(1)
+	if err != nil {
+		t.Fatalf("GenAuthToken() error = %v", err)
+	}
+	if err = svc.RevokeAuthToken(login.RefreshToken); err != nil {
+		t.Fatalf("RevokeAuthToken() error = %v", err)
+	}
+	if revoked, err := cache.IsSessionRevoked(ctx, login.SessionId); err != nil || !revoked {
+		t.Errorf("IsSessionRevoked() = %v, %v, want revoked", revoked, err)
+	}
+	if _, err = svc.RefreshAuthToken(login.RefreshToken); !errors.Is(err, errcode.ErrToken) {
+		t.Errorf("refresh after revoke error = %v, want ErrToken", err)
+	}
+	if err = svc.RevokeAuthToken(login.RefreshToken); !errors.Is(err, errcode.ErrToken) {
+		t.Errorf("revoke twice error = %v, want ErrToken", err)
+	}
+}
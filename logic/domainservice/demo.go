@@ -5,6 +5,7 @@ import (
 	"github/lhh-gh/go-mall/comon/errcode"
 	"github/lhh-gh/go-mall/comon/util"
 	"github/lhh-gh/go-mall/dal/dao"
+	"github/lhh-gh/go-mall/dal/model"
 	"github/lhh-gh/go-mall/logic/do"
 )
 
@@ -74,15 +75,23 @@ func (dds *DemoDomainSvc) CreateDemoOrder(demoOrder *do.DemoOrder) (*do.DemoOrde
 	// 生成订单号  先随便写个
 	demoOrder.OrderNo = "20240627596615375920904456"
 
-	demoOrderModel, err := dds.DemoDao.CreateDemoOrder(demoOrder)
-
+	// 订单和订单商品快照在同一个事务中写入
+	var demoOrderModel *model.DemoOrder
+	err := dao.WithTx(dds.ctx, func(ctx context.Context) (err error) {
+		demoDao := dao.NewDemoDao(ctx)
+		demoOrderModel, err = demoDao.CreateDemoOrder(demoOrder)
+		if err != nil {
+			return errcode.Wrap("创建DemoOrder失败", err)
+		}
+		if err = demoDao.CreateDemoOrderGoodsSnapshot(demoOrderModel, demoOrder.OrderGoodsId); err != nil {
+			return errcode.Wrap("写订单商品快照失败", err)
+		}
+		return nil
+	})
 	if err != nil {
-		err = errcode.Wrap("创建DemoOrder失败", err)
 		return nil, err
 	}
 
-	// TODO1: 写订单快照
-	// 这里一般要在事务里写订单商品快照表, 这个等后面做需求时再演示
 	err = util.CopyProperties(demoOrder, demoOrderModel)
 	// 返回领域对象
 	return demoOrder, err
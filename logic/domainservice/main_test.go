@@ -0,0 +1,24 @@
+package domainservice
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/dal/cache"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Token的会话保存在Redis中, 用内存Redis测试
+	testRedis := miniredis.NewMiniRedis()
+	testRedis.RequireAuth(config.Redis.Password)
+	if err := testRedis.Start(); err != nil {
+		panic(err)
+	}
+	config.Redis.Addr = testRedis.Addr()
+	cache.InitRedis()
+
+	code := m.Run()
+	testRedis.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,98 @@
+package domainservice
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/comon/auth"
+	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/dal/cache"
+	"github/lhh-gh/go-mall/dal/dao"
+	"github/lhh-gh/go-mall/logic/do"
+	"sync"
+)
+
+type OpenAppDomainSvc struct {
+	ctx        context.Context
+	openAppDao *dao.OpenAppDao
+}
+
+func NewOpenAppDomainSvc(ctx context.Context) *OpenAppDomainSvc {
+	return &OpenAppDomainSvc{
+		ctx:        ctx,
+		openAppDao: dao.NewOpenAppDao(ctx),
+	}
+}
+
+// openAppSecrets AppKey => AppSecret
+// 签名密钥不写入Redis, 应用创建后密钥不会变化, 从数据库读取后保存在进程内
+var openAppSecrets sync.Map
+
+// GetOpenApp 按AppKey获取应用, 优先读缓存, 应用不存在时返回 nil
+// 应用信息的缓存中没有 AppSecret, 进程内没有这个应用的密钥时从数据库读取
+func (oas *OpenAppDomainSvc) GetOpenApp(appKey string) (*do.OpenApp, error) {
+	openApp, err := cache.GetOpenApp(oas.ctx, appKey)
+	if err == nil && openApp != nil {
+		if appSecret, ok := openAppSecrets.Load(appKey); ok {
+			openApp.AppSecret = appSecret.(string)
+			return openApp, nil
+		}
+	}
+	openAppModel, err := oas.openAppDao.GetOpenAppByKey(appKey)
+	if err != nil {
+		return nil, errcode.Wrap("查询开放平台应用失败", err)
+	}
+	if openAppModel == nil {
+		return nil, nil
+	}
+	openApp = new(do.OpenApp)
+	if err = util.CopyProperties(openApp, openAppModel); err != nil {
+		return nil, errcode.ErrCoverData.WithCause(err)
+	}
+	openAppSecrets.Store(appKey, openApp.AppSecret)
+	// 写缓存失败不影响本次的验签
+	cache.SetOpenApp(oas.ctx, openApp)
+
+	return openApp, nil
+}
+
+// CreateOpenApp 创建应用, 生成AppKey和AppSecret
+func (oas *OpenAppDomainSvc) CreateOpenApp(name string) (*do.OpenApp, error) {
+	openApp := &do.OpenApp{
+		AppKey:    auth.RandomToken(8),
+		AppSecret: auth.RandomToken(32),
+		Name:      name,
+		Status:    enum.OpenAppStatusEnabled,
+	}
+	openAppModel, err := oas.openAppDao.CreateOpenApp(openApp)
+	if err != nil {
+		return nil, errcode.Wrap("创建开放平台应用失败", err)
+	}
+	openApp.Id = openAppModel.Id
+	openApp.CreatedAt = openAppModel.CreatedAt
+	openApp.UpdatedAt = openAppModel.UpdatedAt
+
+	return openApp, nil
+}
+
+// UpdateOpenAppStatus 启用或停用应用
+func (oas *OpenAppDomainSvc) UpdateOpenAppStatus(appKey string, enabled bool) error {
+	status := enum.OpenAppStatusDisabled
+	if enabled {
+		status = enum.OpenAppStatusEnabled
+	}
+	updated, err := oas.openAppDao.UpdateOpenAppStatus(appKey, status)
+	if err != nil {
+		return errcode.Wrap("更新开放平台应用状态失败", err)
+	}
+	if !updated {
+		return errcode.ErrOpenAppNotExists
+	}
+	if err = cache.DelOpenApp(oas.ctx, appKey); err != nil {
+		// 删除失败时应用信息的缓存最多在有效期后更新
+		logger.New(oas.ctx).Error("invalidate open app error", "appKey", appKey, "err", err)
+	}
+
+	return nil
+}
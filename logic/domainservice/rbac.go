@@ -0,0 +1,157 @@
+package domainservice
+
+import (
+	"context"
+	"github/lhh-gh/go-mall/comon/errcode"
+	"github/lhh-gh/go-mall/comon/logger"
+	"github/lhh-gh/go-mall/comon/util"
+	"github/lhh-gh/go-mall/dal/cache"
+	"github/lhh-gh/go-mall/dal/dao"
+	"github/lhh-gh/go-mall/logic/do"
+	"strings"
+)
+
+type RbacDomainSvc struct {
+	ctx     context.Context
+	rbacDao *dao.RbacDao
+}
+
+func NewRbacDomainSvc(ctx context.Context) *RbacDomainSvc {
+	return &RbacDomainSvc{
+		ctx:     ctx,
+		rbacDao: dao.NewRbacDao(ctx),
+	}
+}
+
+// GetUserPermissions 获取用户拥有的权限标识, 优先读缓存
+func (rds *RbacDomainSvc) GetUserPermissions(userId int64) ([]string, error) {
+	codes, err := cache.GetUserPermissions(rds.ctx, userId)
+	if err == nil && codes != nil {
+		return codes, nil
+	}
+	// 回填缓存的数据读主库: 授权变更后会删除缓存, 从库复制有延迟时读从库会把变更前的权限重新写回缓存,
+	// 直到缓存过期前都使用旧权限
+	codes, err = dao.NewRbacDao(dao.ForceMaster(rds.ctx)).GetUserPermissionCodes(userId)
+	if err != nil {
+		return nil, errcode.Wrap("查询用户权限失败", err)
+	}
+	// 写缓存失败不影响本次的权限判断
+	cache.SetUserPermissions(rds.ctx, userId, codes)
+
+	return codes, nil
+}
+
+// HasPermission 判断用户是否拥有权限
+// 用户拥有 资源:* 时拥有资源下的所有权限, 拥有 * 时拥有全部权限
+func (rds *RbacDomainSvc) HasPermission(userId int64, permission string) (bool, error) {
+	codes, err := rds.GetUserPermissions(userId)
+	if err != nil {
+		return false, err
+	}
+	resource := permission
+	if idx := strings.Index(permission, ":"); idx >= 0 {
+		resource = permission[:idx]
+	}
+	for _, code := range codes {
+		if code == permission || code == "*" || code == resource+":*" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetUserRoles 获取用户绑定的角色
+func (rds *RbacDomainSvc) GetUserRoles(userId int64) ([]*do.Role, error) {
+	roles, err := rds.rbacDao.GetUserRoles(userId)
+	if err != nil {
+		return nil, errcode.Wrap("查询用户角色失败", err)
+	}
+	roleDos := make([]*do.Role, 0, len(roles))
+	for _, role := range roles {
+		roleDo := new(do.Role)
+		util.CopyProperties(roleDo, role)
+		roleDos = append(roleDos, roleDo)
+	}
+
+	return roleDos, nil
+}
+
+// BindUserRole 为用户绑定角色
+func (rds *RbacDomainSvc) BindUserRole(userId int64, roleCode string) error {
+	role, err := rds.rbacDao.GetRoleByCode(roleCode)
+	if err != nil {
+		return errcode.Wrap("查询角色失败", err)
+	}
+	if role == nil {
+		return errcode.ErrRoleNotExists
+	}
+	if err = rds.rbacDao.CreateUserRoleBinding(userId, role.Id); err != nil {
+		return errcode.Wrap("绑定用户角色失败", err)
+	}
+	rds.invalidateUserPermissions(userId)
+
+	return nil
+}
+
+// UnbindUserRole 解除用户与角色的绑定
+func (rds *RbacDomainSvc) UnbindUserRole(userId int64, roleCode string) error {
+	role, err := rds.rbacDao.GetRoleByCode(roleCode)
+	if err != nil {
+		return errcode.Wrap("查询角色失败", err)
+	}
+	if role == nil {
+		return errcode.ErrRoleNotExists
+	}
+	if err = rds.rbacDao.DeleteUserRoleBinding(userId, role.Id); err != nil {
+		return errcode.Wrap("解绑用户角色失败", err)
+	}
+	rds.invalidateUserPermissions(userId)
+
+	return nil
+}
+
+// GrantRolePermission 为角色授予权限
+func (rds *RbacDomainSvc) GrantRolePermission(roleCode, permissionCode string) error {
+	return rds.changeRolePermission(roleCode, permissionCode, rds.rbacDao.CreateRolePermission)
+}
+
+// RevokeRolePermission 收回角色的权限
+func (rds *RbacDomainSvc) RevokeRolePermission(roleCode, permissionCode string) error {
+	return rds.changeRolePermission(roleCode, permissionCode, rds.rbacDao.DeleteRolePermission)
+}
+
+func (rds *RbacDomainSvc) changeRolePermission(roleCode, permissionCode string, change func(roleId, permissionId int64) error) error {
+	role, err := rds.rbacDao.GetRoleByCode(roleCode)
+	if err != nil {
+		return errcode.Wrap("查询角色失败", err)
+	}
+	if role == nil {
+		return errcode.ErrRoleNotExists
+	}
+	permission, err := rds.rbacDao.GetPermissionByCode(permissionCode)
+	if err != nil {
+		return errcode.Wrap("查询权限失败", err)
+	}
+	if permission == nil {
+		return errcode.ErrPermissionNotExists
+	}
+	if err = change(role.Id, permission.Id); err != nil {
+		return errcode.Wrap("变更角色权限失败", err)
+	}
+	// 角色的权限变了, 绑定了这个角色的用户的权限缓存都要删除
+	userIds, err := rds.rbacDao.GetRoleUserIds(role.Id)
+	if err != nil {
+		return errcode.Wrap("查询角色绑定的用户失败", err)
+	}
+	rds.invalidateUserPermissions(userIds...)
+
+	return nil
+}
+
+func (rds *RbacDomainSvc) invalidateUserPermissions(userIds ...int64) {
+	if err := cache.DelUserPermissions(rds.ctx, userIds...); err != nil {
+		// 删除失败时权限缓存最多在有效期后更新
+		logger.New(rds.ctx).Error("invalidate user permissions error", "userIds", userIds, "err", err)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github/lhh-gh/go-mall/api/router"
 	"github/lhh-gh/go-mall/comon/enum"
+	"github/lhh-gh/go-mall/comon/featureflag"
 	"github/lhh-gh/go-mall/config"
+	"github/lhh-gh/go-mall/dal/cache"
+	"github/lhh-gh/go-mall/dal/dao"
 )
 
 func main() {
@@ -13,7 +16,18 @@ func main() {
 	}
 
 	g := gin.New()
+	// gin.Context 的 Deadline/Done/Err 使用 Request 的 Context, 超时中间件设置的截止时间才能传递到DB、Redis和HTTP调用中
+	g.ContextWithFallback = true
+	// 只信任配置的反向代理设置的 X-Forwarded-For, 否则客户端可以伪造IP绕过按IP的限流和访问规则
+	if err := g.SetTrustedProxies(config.App.TrustedProxies); err != nil {
+		panic(err)
+	}
 
+	// 先连接数据库和Redis, 连接不上时让项目停止启动
+	dao.InitDB()
+	cache.InitRedis()
+	// 功能开关的覆盖配置保存在Redis中
+	featureflag.SetOverrideStore(cache.FeatureFlagStore{})
 	router.RegisterRoutes(g)
 
 	g.Run(":8080")